@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// writerun writes the values yielded by next to a new temp file in dir as
+// fixed-width little-endian int64s, returning the file's path. The file is
+// removed if writing fails.
+func writerun(dir string, next func() (int, bool)) (string, error) {
+	f, err := os.CreateTemp(dir, "day01-run-*")
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(f)
+	var buf [8]byte
+	for v, ok := next(); ok; v, ok = next() {
+		binary.LittleEndian.PutUint64(buf[:], uint64(v))
+		if _, err := w.Write(buf[:]); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return "", err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// spill sorts values and writes them to a new run in dir.
+func spill(values []int, dir string) (string, error) {
+	slices.Sort(values)
+	i := 0
+	return writerun(dir, func() (int, bool) {
+		if i == len(values) {
+			return 0, false
+		}
+		i++
+		return values[i-1], true
+	})
+}
+
+// spillcolumns streams the location lists from input, writing a sorted run for
+// each column every time runsize values have been buffered.
+func spillcolumns(input io.Reader, runsize int, dir string) ([]string, []string, error) {
+	var leftruns, rightruns []string
+	left := make([]int, 0, runsize)
+	right := make([]int, 0, runsize)
+	flush := func() error {
+		if len(left) == 0 {
+			return nil
+		}
+		path, err := spill(left, dir)
+		if err != nil {
+			return err
+		}
+		leftruns = append(leftruns, path)
+		path, err = spill(right, dir)
+		if err != nil {
+			return err
+		}
+		rightruns = append(rightruns, path)
+		left, right = left[:0], right[:0]
+		return nil
+	}
+
+	var p lineparser
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		l, r, ok, err := p.next(strings.TrimSuffix(scanner.Text(), "\r"))
+		if err != nil {
+			return leftruns, rightruns, err
+		}
+		if !ok {
+			continue
+		}
+		left = append(left, l)
+		right = append(right, r)
+		if len(left) == runsize {
+			if err := flush(); err != nil {
+				return leftruns, rightruns, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return leftruns, rightruns, err
+	}
+	err := flush()
+	return leftruns, rightruns, err
+}
+
+type run struct {
+	f   *os.File
+	r   *bufio.Reader
+	cur int
+}
+
+func (r *run) advance() error {
+	var buf [8]byte
+	if _, err := io.ReadFull(r.r, buf[:]); err != nil {
+		return err
+	}
+	r.cur = int(binary.LittleEndian.Uint64(buf[:]))
+	return nil
+}
+
+// merger yields the values of several sorted runs in ascending order, holding
+// one value per run in memory. Like bufio.Scanner, it records the first error
+// it hits and stops; callers check err once next reports false.
+type merger struct {
+	runs []*run
+	err  error
+}
+
+func (m *merger) Len() int           { return len(m.runs) }
+func (m *merger) Less(i, j int) bool { return m.runs[i].cur < m.runs[j].cur }
+func (m *merger) Swap(i, j int)      { m.runs[i], m.runs[j] = m.runs[j], m.runs[i] }
+func (m *merger) Push(x any)         { m.runs = append(m.runs, x.(*run)) }
+func (m *merger) Pop() any {
+	r := m.runs[len(m.runs)-1]
+	m.runs = m.runs[:len(m.runs)-1]
+	return r
+}
+
+func openmerger(paths []string) (*merger, error) {
+	m := &merger{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			m.close()
+			return nil, err
+		}
+		r := &run{f: f, r: bufio.NewReader(f)}
+		if err := r.advance(); err != nil {
+			f.Close()
+			if errors.Is(err, io.EOF) {
+				continue
+			}
+			m.close()
+			return nil, err
+		}
+		m.runs = append(m.runs, r)
+	}
+	heap.Init(m)
+	return m, nil
+}
+
+func (m *merger) next() (int, bool) {
+	if m.err != nil || len(m.runs) == 0 {
+		return 0, false
+	}
+	top := m.runs[0]
+	v := top.cur
+	if err := top.advance(); err != nil {
+		top.f.Close()
+		heap.Pop(m)
+		if !errors.Is(err, io.EOF) {
+			m.err = err
+		}
+	} else {
+		heap.Fix(m, 0)
+	}
+	return v, true
+}
+
+func (m *merger) close() {
+	for _, r := range m.runs {
+		r.f.Close()
+	}
+	m.runs = nil
+}
+
+// mergerun merges the runs at paths into a single new run in dir.
+func mergerun(paths []string, dir string) (string, error) {
+	m, err := openmerger(paths)
+	if err != nil {
+		return "", err
+	}
+	defer m.close()
+	path, err := writerun(dir, m.next)
+	if err != nil {
+		return "", err
+	}
+	if m.err != nil {
+		os.Remove(path)
+		return "", m.err
+	}
+	return path, nil
+}
+
+// mergeruns merges the runs at paths, fanin at a time, until at most fanin
+// are left, so that no more than fanin of them are ever open at once. Merged
+// runs are removed. It returns the paths of the runs that remain, even on
+// error, so that the caller can remove them.
+func mergeruns(paths []string, fanin int, dir string) ([]string, error) {
+	for len(paths) > fanin {
+		var merged []string
+		for len(paths) > 0 {
+			group := paths[:min(fanin, len(paths))]
+			path, err := mergerun(group, dir)
+			if err != nil {
+				return slices.Concat(merged, paths), err
+			}
+			for _, p := range group {
+				os.Remove(p)
+			}
+			merged = append(merged, path)
+			paths = paths[len(group):]
+		}
+		paths = merged
+	}
+	return paths, nil
+}
+
+func externaldistance(leftruns []string, rightruns []string) (int, error) {
+	left, err := openmerger(leftruns)
+	if err != nil {
+		return 0, err
+	}
+	defer left.close()
+	right, err := openmerger(rightruns)
+	if err != nil {
+		return 0, err
+	}
+	defer right.close()
+
+	dist := 0
+	for {
+		l, lok := left.next()
+		r, rok := right.next()
+		if !lok || !rok {
+			break
+		}
+		d := l - r
+		if d < 0 {
+			d = -d
+		}
+		dist += d
+	}
+	return dist, errors.Join(left.err, right.err)
+}
+
+func externalsimilarity(leftruns []string, rightruns []string) (int, error) {
+	left, err := openmerger(leftruns)
+	if err != nil {
+		return 0, err
+	}
+	defer left.close()
+	right, err := openmerger(rightruns)
+	if err != nil {
+		return 0, err
+	}
+	defer right.close()
+
+	similarity := 0
+	l, lok := left.next()
+	r, rok := right.next()
+	for lok && rok {
+		switch {
+		case l < r:
+			l, lok = left.next()
+		case l > r:
+			r, rok = right.next()
+		default:
+			v := l
+			nl, nr := 0, 0
+			for lok && l == v {
+				nl++
+				l, lok = left.next()
+			}
+			for rok && r == v {
+				nr++
+				r, rok = right.next()
+			}
+			similarity += v * nl * nr
+		}
+	}
+	return similarity, errors.Join(left.err, right.err)
+}
+
+// solveexternal computes the total distance and similarity score of the lists
+// in r while holding at most runsize values per list in memory. Sorted runs
+// are spilled to temp files in dir (os.TempDir if empty) and removed after.
+// Each column's runs are merged down to at most fanin before the final pass,
+// which keeps at most 2*fanin files open.
+func solveexternal(r io.Reader, runsize int, fanin int, dir string) (int, int, error) {
+	if runsize < 1 {
+		return 0, 0, fmt.Errorf("runsize must be positive, got %d", runsize)
+	}
+	if fanin < 2 {
+		return 0, 0, fmt.Errorf("fanin must be at least 2, got %d", fanin)
+	}
+	leftruns, rightruns, err := spillcolumns(r, runsize, dir)
+	defer func() {
+		for _, path := range slices.Concat(leftruns, rightruns) {
+			os.Remove(path)
+		}
+	}()
+	if err != nil {
+		return 0, 0, err
+	}
+	leftruns, err = mergeruns(leftruns, fanin, dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	rightruns, err = mergeruns(rightruns, fanin, dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	dist, err := externaldistance(leftruns, rightruns)
+	if err != nil {
+		return 0, 0, err
+	}
+	similarity, err := externalsimilarity(leftruns, rightruns)
+	if err != nil {
+		return 0, 0, err
+	}
+	return dist, similarity, nil
+}
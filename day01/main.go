@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -8,28 +10,107 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
-func parse(s string) ([]int, []int) {
-	lines := strings.Split(s, "\n")
+// lineparser reads location list lines one at a time. It accepts whitespace,
+// tab or comma separated pairs, skips blank lines, # comments and a single
+// header row before the first pair, and reports errors with line numbers.
+type lineparser struct {
+	lineno int
+	seen   bool
+}
+
+func splitfields(line string) []string {
+	if !strings.Contains(line, ",") {
+		return strings.Fields(line)
+	}
+	parts := strings.Split(line, ",")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"`)
+	}
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return parts
+}
+
+// isheader reports whether parts are column labels: none parses as an integer
+// and each starts with a letter followed by letters, digits, spaces, '_' or
+// '-'. Anything else is left to next's arity and integer checks.
+func isheader(parts []string) bool {
+	for _, part := range parts {
+		if _, err := strconv.Atoi(part); err == nil || !islabel(part) {
+			return false
+		}
+	}
+	return true
+}
+
+func islabel(s string) bool {
+	for i, c := range s {
+		switch {
+		case unicode.IsLetter(c):
+		case i > 0 && (unicode.IsDigit(c) || c == ' ' || c == '_' || c == '-'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// next parses one line. ok is false if the line held no pair.
+func (p *lineparser) next(line string) (l int, r int, ok bool, err error) {
+	p.lineno++
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	parts := splitfields(line)
+	if len(parts) == 0 {
+		return 0, 0, false, nil
+	}
+	if !p.seen && isheader(parts) {
+		p.seen = true
+		return 0, 0, false, nil
+	}
+	p.seen = true
+	if len(parts) != 2 {
+		return 0, 0, false, fmt.Errorf("line %d: expected 2 location IDs, got %d in %q", p.lineno, len(parts), line)
+	}
+	l, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("line %d: %w", p.lineno, err)
+	}
+	r, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("line %d: %w", p.lineno, err)
+	}
+	return l, r, true, nil
+}
+
+func parselists(s string) ([]int, []int, error) {
+	var p lineparser
 	var left []int
 	var right []int
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.Fields(line)
-		l, err := strconv.Atoi(parts[0])
+	for _, line := range strings.Split(s, "\n") {
+		l, r, ok, err := p.next(strings.TrimSuffix(line, "\r"))
 		if err != nil {
-			log.Fatal(err)
+			return nil, nil, err
 		}
-		left = append(left, l)
-		r, err := strconv.Atoi(parts[1])
-		if err != nil {
-			log.Fatal(err)
+		if !ok {
+			continue
 		}
+		left = append(left, l)
 		right = append(right, r)
 	}
+	return left, right, nil
+}
+
+func parse(s string) ([]int, []int) {
+	left, right, err := parselists(s)
+	if err != nil {
+		log.Fatal(err)
+	}
 	return left, right
 }
 
@@ -48,31 +129,97 @@ func part1(input string) string {
 	return fmt.Sprint(dist)
 }
 
-func part2(input string) string {
-	left, right := parse(input)
+func similaritymap(left []int, right []int) int {
+	counts := make(map[int]int, len(right))
+	for _, r := range right {
+		counts[r]++
+	}
 	similarity := 0
 	for _, l := range left {
-		sim := 0
-		for _, r := range right {
-			if l == r {
-				sim++
+		similarity += l * counts[l]
+	}
+	return similarity
+}
+
+// similaritysorted sorts both lists in place and walks them together,
+// allocating nothing.
+func similaritysorted(left []int, right []int) int {
+	slices.Sort(left)
+	slices.Sort(right)
+	similarity := 0
+	i, j := 0, 0
+	for i < len(left) && j < len(right) {
+		switch {
+		case left[i] < right[j]:
+			i++
+		case left[i] > right[j]:
+			j++
+		default:
+			v := left[i]
+			nl, nr := 0, 0
+			for ; i < len(left) && left[i] == v; i++ {
+				nl++
 			}
+			for ; j < len(right) && right[j] == v; j++ {
+				nr++
+			}
+			similarity += v * nl * nr
 		}
-		similarity += sim * l
 	}
-	return fmt.Sprint(similarity)
+	return similarity
+}
+
+func part2(input string) string {
+	left, right := parse(input)
+	return fmt.Sprint(similaritymap(left, right))
 }
 
 func main() {
+	external := flag.Bool("external", false, "sort the lists through temp files instead of in memory")
+	runsize := flag.Int("runsize", 1<<20, "values per list held in memory at once with -external")
+	fanin := flag.Int("fanin", 128, "runs per list merged at once with -external")
+	report := flag.Bool("stats", false, "print a statistics report over the two lists")
+	asjson := flag.Bool("json", false, "print the -stats report as JSON")
+	lowmem := flag.Bool("lowmem", false, "compute the similarity score by sorting instead of with a count table")
+	flag.Parse()
+
 	f, err := os.Open("data/day01.txt")
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *external {
+		dist, similarity, err := solveexternal(f, *runsize, *fanin, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Part 1: %d\n", dist)
+		fmt.Printf("Part 2: %d\n", similarity)
+		return
+	}
 	b, err := io.ReadAll(f)
 	if err != nil {
 		log.Fatal(err)
 	}
 	s := string(b)
+	if *report {
+		left, right := parse(s)
+		r := stats(left, right, 10, 20)
+		if !*asjson {
+			fmt.Print(r)
+			return
+		}
+		out, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
 	fmt.Printf("Part 1: %s\n", part1(s))
+	if *lowmem {
+		left, right := parse(s)
+		fmt.Printf("Part 2: %d\n", similaritysorted(left, right))
+		return
+	}
 	fmt.Printf("Part 2: %s\n", part2(s))
 }
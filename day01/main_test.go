@@ -1,9 +1,15 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -69,6 +75,323 @@ func Test_part2(t *testing.T) {
 	}
 }
 
+func Test_parselists(t *testing.T) {
+	type args struct {
+		input string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantleft  []int
+		wantright []int
+		wanterr   string
+	}{
+		{
+			name:      "ragged whitespace",
+			args:      args{input: "3   4\n\t4 3  \n\n  2\t\t5\n"},
+			wantleft:  []int{3, 4, 2},
+			wantright: []int{4, 3, 5},
+		},
+		{
+			name:      "csv with header and comments",
+			args:      args{input: "# exported 2024-12-01\nleft,right\r\n3,4\r\n\"-4\", +3 # adjusted\r\n9223372036854775807,0,\r\n"},
+			wantleft:  []int{3, -4, 9223372036854775807},
+			wantright: []int{4, 3, 0},
+		},
+		{
+			name:      "tsv with header",
+			args:      args{input: "Left\tRight\n3\t4\n4\t3\n"},
+			wantleft:  []int{3, 4},
+			wantright: []int{4, 3},
+		},
+		{
+			name:    "too few fields",
+			args:    args{input: "3   4\n4\n"},
+			wanterr: `line 2: expected 2 location IDs, got 1 in "4"`,
+		},
+		{
+			name:    "too many fields",
+			args:    args{input: "3,4,5\n"},
+			wanterr: `line 1: expected 2 location IDs, got 3 in "3,4,5"`,
+		},
+		{
+			name:    "header after data",
+			args:    args{input: "3   4\nleft right\n"},
+			wanterr: `line 2: strconv.Atoi: parsing "left": invalid syntax`,
+		},
+		{
+			name:    "malformed first row",
+			args:    args{input: "1e3 4\n5 6\n"},
+			wanterr: `line 1: strconv.Atoi: parsing "1e3": invalid syntax`,
+		},
+		{
+			name:    "partial header",
+			args:    args{input: "left 4\n5 6\n"},
+			wanterr: `line 1: strconv.Atoi: parsing "left": invalid syntax`,
+		},
+		{
+			name:    "overflow",
+			args:    args{input: "3   99999999999999999999\n"},
+			wanterr: `line 1: strconv.Atoi: parsing "99999999999999999999": value out of range`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right, err := parselists(tt.args.input)
+			if tt.wanterr != "" {
+				if err == nil || err.Error() != tt.wanterr {
+					t.Errorf("parselists() error = %v, want %v", err, tt.wanterr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parselists() error = %v", err)
+			}
+			if !reflect.DeepEqual(left, tt.wantleft) || !reflect.DeepEqual(right, tt.wantright) {
+				t.Errorf("parselists() = %v, %v, want %v, %v", left, right, tt.wantleft, tt.wantright)
+			}
+		})
+	}
+}
+
+func Test_similarity(t *testing.T) {
+	type args struct {
+		left  []int
+		right []int
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "test input",
+			args: args{
+				left:  []int{3, 4, 2, 1, 3, 3},
+				right: []int{4, 3, 5, 3, 9, 3},
+			},
+			want: 31,
+		},
+		{
+			name: "repeats on both sides",
+			args: args{
+				left:  []int{5, 5, 1, 7},
+				right: []int{5, 7, 5, 5, 2},
+			},
+			want: 37,
+		},
+		{
+			name: "empty",
+			args: args{},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := similaritymap(tt.args.left, tt.args.right); got != tt.want {
+				t.Errorf("similaritymap() = %v, want %v", got, tt.want)
+			}
+			left, right := slices.Clone(tt.args.left), slices.Clone(tt.args.right)
+			if got := similaritysorted(left, right); got != tt.want {
+				t.Errorf("similaritysorted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func randomlists(n int) ([]int, []int) {
+	r := rand.New(rand.NewSource(int64(n)))
+	left := make([]int, n)
+	right := make([]int, n)
+	for i := range n {
+		left[i] = 10000 + r.Intn(n)
+		right[i] = 10000 + r.Intn(n)
+	}
+	return left, right
+}
+
+func BenchmarkSimilarity(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000, 100000, 1000000} {
+		left, right := randomlists(n)
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				similaritymap(left, right)
+			}
+		})
+		b.Run(fmt.Sprintf("sorted/%d", n), func(b *testing.B) {
+			l, r := make([]int, n), make([]int, n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				b.StopTimer()
+				copy(l, left)
+				copy(r, right)
+				b.StartTimer()
+				similaritysorted(l, r)
+			}
+		})
+	}
+}
+
+func Test_solveexternal(t *testing.T) {
+	var random strings.Builder
+	left, right := randomlists(1000)
+	for i := range left {
+		fmt.Fprintf(&random, "%d   %d\n", left[i], right[i])
+	}
+	type args struct {
+		input   string
+		runsize int
+		fanin   int
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantdist       int
+		wantsimilarity int
+	}{
+		{
+			name: "test input",
+			args: args{
+				input:   "3   4\n4   3\n2   5\n1   3\n3   9\n3   3\n",
+				runsize: 2,
+				fanin:   16,
+			},
+			wantdist:       11,
+			wantsimilarity: 31,
+		},
+		{
+			name: "single run",
+			args: args{
+				input:   "3   4\n4   3\n2   5\n1   3\n3   9\n3   3\n",
+				runsize: 100,
+				fanin:   16,
+			},
+			wantdist:       11,
+			wantsimilarity: 31,
+		},
+		{
+			name: "random lists",
+			args: args{
+				input:   random.String(),
+				runsize: 37,
+				fanin:   16,
+			},
+			wantdist:       atoi(part1(random.String())),
+			wantsimilarity: atoi(part2(random.String())),
+		},
+		{
+			name: "more runs than the fan-in",
+			args: args{
+				input:   random.String(),
+				runsize: 1,
+				fanin:   4,
+			},
+			wantdist:       atoi(part1(random.String())),
+			wantsimilarity: atoi(part2(random.String())),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dist, similarity, err := solveexternal(strings.NewReader(tt.args.input), tt.args.runsize, tt.args.fanin, dir)
+			if err != nil {
+				t.Fatalf("solveexternal() error = %v", err)
+			}
+			if dist != tt.wantdist {
+				t.Errorf("solveexternal() dist = %v, want %v", dist, tt.wantdist)
+			}
+			if similarity != tt.wantsimilarity {
+				t.Errorf("solveexternal() similarity = %v, want %v", similarity, tt.wantsimilarity)
+			}
+			if left, _ := os.ReadDir(dir); len(left) != 0 {
+				t.Errorf("solveexternal() left %d run files behind", len(left))
+			}
+		})
+	}
+}
+
+func atoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return n
+}
+
+func Test_stats(t *testing.T) {
+	type args struct {
+		input    string
+		topgaps  int
+		nbuckets int
+	}
+	tests := []struct {
+		name string
+		args args
+		want Report
+	}{
+		{
+			name: "test input",
+			args: args{
+				input:    "3   4\n4   3\n2   5\n1   3\n3   9\n3   3\n",
+				topgaps:  2,
+				nbuckets: 5,
+			},
+			want: Report{
+				Left:         ListStats{Count: 6, Min: 1, Max: 4, Median: 3},
+				Right:        ListStats{Count: 6, Min: 3, Max: 9, Median: 3.5},
+				ExactMatches: 1,
+				LargestGaps: []Gap{
+					{Index: 5, Left: 4, Right: 9, Gap: 5},
+					{Index: 0, Left: 1, Right: 3, Gap: 2},
+				},
+				Histogram: []Bucket{
+					{Lo: 0, Hi: 1, Count: 3},
+					{Lo: 2, Hi: 2, Count: 2},
+					{Lo: 3, Hi: 3, Count: 0},
+					{Lo: 4, Hi: 4, Count: 0},
+					{Lo: 5, Hi: 5, Count: 1},
+				},
+			},
+		},
+		{
+			name: "more buckets than gap values",
+			args: args{
+				input:    "1   3\n2   2\n",
+				topgaps:  1,
+				nbuckets: 5,
+			},
+			want: Report{
+				Left:        ListStats{Count: 2, Min: 1, Max: 2, Median: 1.5},
+				Right:       ListStats{Count: 2, Min: 2, Max: 3, Median: 2.5},
+				LargestGaps: []Gap{{Index: 0, Left: 1, Right: 2, Gap: 1}},
+				Histogram: []Bucket{
+					{Lo: 0, Hi: 0, Count: 0},
+					{Lo: 1, Hi: 1, Count: 2},
+				},
+			},
+		},
+		{
+			name: "empty",
+			args: args{
+				input:    "",
+				topgaps:  2,
+				nbuckets: 5,
+			},
+			want: Report{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right := parse(tt.args.input)
+			if got := stats(left, right, tt.args.topgaps, tt.args.nbuckets); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
 func BenchmarkPart1(b *testing.B) {
 	f, err := os.Open("../data/day01.txt")
 	if err != nil {
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type ListStats struct {
+	Count  int     `json:"count"`
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Median float64 `json:"median"`
+}
+
+// Gap is the distance between the i-th smallest IDs of the two lists.
+type Gap struct {
+	Index int `json:"index"`
+	Left  int `json:"left"`
+	Right int `json:"right"`
+	Gap   int `json:"gap"`
+}
+
+// Bucket counts the pairs whose gap lies in [Lo, Hi].
+type Bucket struct {
+	Lo    int `json:"lo"`
+	Hi    int `json:"hi"`
+	Count int `json:"count"`
+}
+
+type Report struct {
+	Left         ListStats `json:"left"`
+	Right        ListStats `json:"right"`
+	ExactMatches int       `json:"exact_matches"`
+	LargestGaps  []Gap     `json:"largest_gaps"`
+	Histogram    []Bucket  `json:"histogram"`
+}
+
+func liststats(sorted []int) ListStats {
+	n := len(sorted)
+	if n == 0 {
+		return ListStats{}
+	}
+	median := float64(sorted[n/2])
+	if n%2 == 0 {
+		median = float64(sorted[n/2-1]+sorted[n/2]) / 2
+	}
+	return ListStats{
+		Count:  n,
+		Min:    sorted[0],
+		Max:    sorted[n-1],
+		Median: median,
+	}
+}
+
+// stats pairs up the sorted lists the same way part1 does and summarises
+// them, keeping the topgaps largest gaps and spreading the gaps over a
+// histogram of nbuckets buckets, or one per gap value if there are fewer
+// values than that. Bucket widths differ by at most one. The input slices are
+// not modified.
+func stats(left []int, right []int, topgaps int, nbuckets int) Report {
+	left, right = slices.Clone(left), slices.Clone(right)
+	slices.Sort(left)
+	slices.Sort(right)
+	report := Report{
+		Left:  liststats(left),
+		Right: liststats(right),
+	}
+
+	var gaps []Gap
+	maxgap := 0
+	for i := range min(len(left), len(right)) {
+		d := left[i] - right[i]
+		if d < 0 {
+			d = -d
+		}
+		if d == 0 {
+			report.ExactMatches++
+		}
+		maxgap = max(maxgap, d)
+		gaps = append(gaps, Gap{Index: i, Left: left[i], Right: right[i], Gap: d})
+	}
+	if len(gaps) == 0 || nbuckets < 1 {
+		return report
+	}
+
+	// Bucket i covers the gaps g with i <= g*n/values < i+1.
+	values := maxgap + 1
+	n := min(nbuckets, values)
+	for i := range n {
+		lo := (i*values + n - 1) / n
+		hi := ((i+1)*values+n-1)/n - 1
+		report.Histogram = append(report.Histogram, Bucket{Lo: lo, Hi: hi})
+	}
+	for _, g := range gaps {
+		report.Histogram[g.Gap*n/values].Count++
+	}
+
+	slices.SortStableFunc(gaps, func(a, b Gap) int {
+		return cmp.Compare(b.Gap, a.Gap)
+	})
+	report.LargestGaps = gaps[:min(topgaps, len(gaps))]
+	return report
+}
+
+func (r Report) String() string {
+	var sb strings.Builder
+	for _, list := range []struct {
+		name  string
+		stats ListStats
+	}{{"Left", r.Left}, {"Right", r.Right}} {
+		fmt.Fprintf(&sb, "%-5s count=%d min=%d max=%d median=%g\n",
+			list.name, list.stats.Count, list.stats.Min, list.stats.Max, list.stats.Median)
+	}
+	fmt.Fprintf(&sb, "Exact matches: %d\n", r.ExactMatches)
+
+	sb.WriteString("Largest gaps:\n")
+	for _, g := range r.LargestGaps {
+		fmt.Fprintf(&sb, "  #%d: %d vs %d (gap %d)\n", g.Index, g.Left, g.Right, g.Gap)
+	}
+
+	sb.WriteString("Gap histogram:\n")
+	most := 0
+	for _, b := range r.Histogram {
+		most = max(most, b.Count)
+	}
+	for _, b := range r.Histogram {
+		bar := 0
+		if most > 0 {
+			bar = b.Count * 40 / most
+		}
+		fmt.Fprintf(&sb, "  %8d-%-8d %6d %s\n", b.Lo, b.Hi, b.Count, strings.Repeat("#", bar))
+	}
+	return sb.String()
+}
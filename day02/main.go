@@ -1,11 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"slices"
 	"strconv"
 	"strings"
 )
@@ -31,84 +31,265 @@ func parse(s string) [][]int {
 	return input
 }
 
-func safeasc(level []int) bool {
-	var prev int
-	for i, x := range level {
-		if i == 0 {
-			prev = x
-			continue
-		}
-		diff := x - prev
-		prev = x
-		if diff < 1 || diff > 3 {
-			return false
-		}
+type Direction int
+
+const (
+	Either Direction = iota
+	Ascending
+	Descending
+)
+
+func parsedirection(s string) (Direction, error) {
+	switch s {
+	case "either":
+		return Either, nil
+	case "asc":
+		return Ascending, nil
+	case "desc":
+		return Descending, nil
 	}
-	return true
+	return Either, fmt.Errorf("unknown direction %q, want either, asc or desc", s)
 }
 
-func safedesc(level []int) bool {
-	var prev int
-	for i, x := range level {
-		if i == 0 {
-			prev = x
-			continue
-		}
-		diff := x - prev
-		prev = x
-		if diff < -3 || diff > -1 {
+// Rule describes when a report is safe: every step between adjacent levels
+// moves in the same direction by between MinStep and MaxStep, or by zero if
+// AllowPlateaus is set.
+type Rule struct {
+	MinStep       int
+	MaxStep       int
+	AllowPlateaus bool
+	Direction     Direction
+}
+
+var DefaultRule = Rule{MinStep: 1, MaxStep: 3}
+
+type Options struct {
+	Rule Rule
+	// Tolerance is the number of bad levels the Problem Dampener may remove.
+	Tolerance int
+}
+
+var DefaultOptions = Options{Rule: DefaultRule, Tolerance: 1}
+
+func (r Rule) ascstep(prev int, x int) bool {
+	diff := x - prev
+	if diff == 0 && r.AllowPlateaus {
+		return true
+	}
+	return diff >= r.MinStep && diff <= r.MaxStep
+}
+
+func (r Rule) descstep(prev int, x int) bool {
+	return r.ascstep(x, prev)
+}
+
+// steps returns a step check for each direction the rule allows.
+func (r Rule) steps() []func(int, int) bool {
+	switch r.Direction {
+	case Ascending:
+		return []func(int, int) bool{r.ascstep}
+	case Descending:
+		return []func(int, int) bool{r.descstep}
+	}
+	return []func(int, int) bool{r.ascstep, r.descstep}
+}
+
+func safedir(level []int, step func(int, int) bool) bool {
+	for i := 1; i < len(level); i++ {
+		if !step(level[i-1], level[i]) {
 			return false
 		}
 	}
 	return true
 }
 
-func safe(level []int) bool {
-	return safeasc(level) || safedesc(level)
+func safe(level []int, rule Rule) bool {
+	for _, step := range rule.steps() {
+		if safedir(level, step) {
+			return true
+		}
+	}
+	return false
 }
 
-func part1(input string) string {
+func solve1(input string, opts Options) string {
 	levels := parse(input)
 	n := 0
 	for _, level := range levels {
-		if safe(level) {
+		if safe(level, opts.Rule) {
 			n++
 		}
 	}
 	return fmt.Sprint(n)
 }
 
-func safedampened(level []int) bool {
+func part1(input string) string {
+	return solve1(input, DefaultOptions)
+}
+
+// Violation is the first adjacent pair of levels, level[I] and level[I+1],
+// that breaks a Rule.
+type Violation struct {
+	I      int
+	Reason string
+}
+
+// diagnose returns the first step in level that breaks rule. When either
+// direction is allowed, the first non-zero step decides which one applies,
+// mirroring safe.
+func diagnose(level []int, rule Rule) (Violation, bool) {
+	sign := 1
+	switch rule.Direction {
+	case Descending:
+		sign = -1
+	case Either:
+		for i := 1; i < len(level); i++ {
+			if level[i] < level[i-1] {
+				sign = -1
+				break
+			} else if level[i] > level[i-1] {
+				break
+			}
+		}
+	}
+	for i := 1; i < len(level); i++ {
+		diff := (level[i] - level[i-1]) * sign
+		if rule.ascstep(0, diff) {
+			continue
+		}
+		reason := "step too small"
+		switch {
+		case diff == 0:
+			reason = "equal levels"
+		case diff < 0 && rule.Direction == Either:
+			reason = "direction change"
+		case diff < 0:
+			reason = "wrong direction"
+		case diff > rule.MaxStep:
+			reason = "step too large"
+		}
+		return Violation{I: i - 1, Reason: reason}, true
+	}
+	return Violation{}, false
+}
+
+// explain lists every report with its verdict under opts. Unsafe reports show
+// their first violation and, if the Problem Dampener can fix them within
+// opts.Tolerance, the indices of the levels it removes.
+func explain(input string, opts Options) string {
+	var sb strings.Builder
+	for _, level := range parse(input) {
+		v, bad := diagnose(level, opts.Rule)
+		if !bad {
+			fmt.Fprintf(&sb, "%v: safe\n", level)
+			continue
+		}
+		verdict := "unsafe"
+		if n, removed := minremovals(level, opts.Tolerance, opts.Rule); n <= opts.Tolerance {
+			parts := make([]string, len(removed))
+			for i, r := range removed {
+				parts[i] = fmt.Sprintf("%d (%d)", r, level[r])
+			}
+			noun := "level"
+			if len(removed) > 1 {
+				noun = "levels"
+			}
+			verdict = fmt.Sprintf("safe after removing %s %s", noun, strings.Join(parts, ", "))
+		}
+		fmt.Fprintf(&sb, "%v: %s; levels %d-%d (%d -> %d): %s\n",
+			level, verdict, v.I, v.I+1, level[v.I], level[v.I+1], v.Reason)
+	}
+	return sb.String()
+}
+
+// minremovalsdir returns the fewest levels that must be removed so that every
+// pair of adjacent remaining levels satisfies step, and their indices, or k+1
+// and nil if more than k are needed. dp[j] is the fewest removals among
+// level[:j+1] that keep level[j], and prev[j] the kept level before it (-1 if
+// none). A solution with at most k removals never skips more than k levels
+// between two kept ones, so only the previous k+1 levels are considered:
+// O(n·k). Ties keep the nearest predecessor, so the earlier level of a bad
+// pair is the one removed.
+func minremovalsdir(level []int, k int, step func(int, int) bool) (int, []int) {
 	n := len(level)
-	var dampened []int
+	if n == 0 {
+		return 0, nil
+	}
+	best, last := k+1, -1
+	dp := make([]int, n)
+	prev := make([]int, n)
+	for j := range level {
+		dp[j], prev[j] = j, -1
+		for i := j - 1; i >= max(0, j-k-1); i-- {
+			if step(level[i], level[j]) && dp[i]+j-i-1 < dp[j] {
+				dp[j], prev[j] = dp[i]+j-i-1, i
+			}
+		}
+		if dp[j]+n-1-j < best {
+			best, last = dp[j]+n-1-j, j
+		}
+	}
+	if last < 0 {
+		return best, nil
+	}
+	kept := make([]bool, n)
+	for j := last; j >= 0; j = prev[j] {
+		kept[j] = true
+	}
+	removed := []int{}
 	for i := range level {
-		if i == 0 {
-			dampened = level[1:]
-		} else if i == len(level)-1 {
-			dampened = level[:n-1]
-		} else {
-			dampened = slices.Concat(level[:i], level[i+1:])
-		}
-		log.Println("Dampened to ", dampened)
-		if safe(dampened) {
-			return true
+		if !kept[i] {
+			removed = append(removed, i)
 		}
 	}
-	return false
+	return best, removed
 }
 
-func part2(input string) string {
+// minremovals returns the fewest levels that must be removed to make level
+// safe under rule and their indices, or k+1 and nil if more than k are
+// needed.
+func minremovals(level []int, k int, rule Rule) (int, []int) {
+	best := k + 1
+	var removed []int
+	for _, step := range rule.steps() {
+		if n, r := minremovalsdir(level, k, step); n < best {
+			best, removed = n, r
+		}
+	}
+	return best, removed
+}
+
+func solve2(input string, opts Options) string {
 	levels := parse(input)
 	n := 0
 	for _, level := range levels {
-		if safedampened(level) {
+		if removals, _ := minremovals(level, opts.Tolerance, opts.Rule); removals <= opts.Tolerance {
 			n++
 		}
 	}
 	return fmt.Sprint(n)
 }
 
+func part2(input string) string {
+	return solve2(input, DefaultOptions)
+}
+
 func main() {
+	opts := DefaultOptions
+	flag.IntVar(&opts.Tolerance, "tolerance", opts.Tolerance, "number of bad levels the Problem Dampener may remove")
+	flag.IntVar(&opts.Rule.MinStep, "min-step", opts.Rule.MinStep, "smallest allowed change between adjacent levels")
+	flag.IntVar(&opts.Rule.MaxStep, "max-step", opts.Rule.MaxStep, "largest allowed change between adjacent levels")
+	flag.BoolVar(&opts.Rule.AllowPlateaus, "plateaus", opts.Rule.AllowPlateaus, "allow adjacent levels to be equal")
+	direction := flag.String("direction", "either", "required direction of a safe report: either, asc or desc")
+	removals := flag.Bool("removals", false, "list the minimum removals needed for each report")
+	explained := flag.Bool("explain", false, "list each report's verdict and why it is unsafe")
+	flag.Parse()
+	var err error
+	opts.Rule.Direction, err = parsedirection(*direction)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	f, err := os.Open("../data/day02.txt")
 	if err != nil {
 		log.Fatal(err)
@@ -118,6 +299,17 @@ func main() {
 		log.Fatal(err)
 	}
 	s := string(b)
-	fmt.Printf("Part 1: %s\n", part1(s))
-	fmt.Printf("Part 2: %s\n", part2(s))
+	if *explained {
+		fmt.Print(explain(s, opts))
+		return
+	}
+	if *removals {
+		for _, level := range parse(s) {
+			n, removed := minremovals(level, len(level), opts.Rule)
+			fmt.Printf("%v: %d %v\n", level, n, removed)
+		}
+		return
+	}
+	fmt.Printf("Part 1: %s\n", solve1(s, opts))
+	fmt.Printf("Part 2: %s\n", solve2(s, opts))
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"math/rand"
+	"slices"
 	"testing"
 )
 
@@ -65,6 +67,184 @@ func Test_part2(t *testing.T) {
 	}
 }
 
+func Test_minremovals(t *testing.T) {
+	type args struct {
+		level []int
+		k     int
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{name: "safe", args: args{level: []int{7, 6, 4, 2, 1}, k: 5}, want: 0},
+		{name: "jump up", args: args{level: []int{1, 2, 7, 8, 9}, k: 5}, want: 2},
+		{name: "jump down", args: args{level: []int{9, 7, 6, 2, 1}, k: 5}, want: 2},
+		{name: "direction change", args: args{level: []int{1, 3, 2, 4, 5}, k: 5}, want: 1},
+		{name: "plateau", args: args{level: []int{8, 6, 4, 4, 1}, k: 5}, want: 1},
+		{name: "two in the middle", args: args{level: []int{1, 2, 9, 9, 3, 4}, k: 5}, want: 2},
+		{name: "capped at k+1", args: args{level: []int{1, 2, 9, 9, 3, 4}, k: 1}, want: 2},
+		{name: "first level bad", args: args{level: []int{20, 1, 2, 3}, k: 1}, want: 1},
+		{name: "empty", args: args{level: nil, k: 0}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := minremovals(tt.args.level, tt.args.k, DefaultRule); got != tt.want {
+				t.Errorf("minremovals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// dampened reports whether removing some single level makes level safe, by
+// trying each in turn.
+func dampened(level []int, rule Rule) bool {
+	for i := range level {
+		if safe(slices.Concat(level[:i], level[i+1:]), rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_minremovals_dampened(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for range 1000 {
+		level := make([]int, 2+r.Intn(8))
+		for i := range level {
+			level[i] = r.Intn(12)
+		}
+		want := safe(level, DefaultRule) || dampened(level, DefaultRule)
+		n, removed := minremovals(level, 1, DefaultRule)
+		if got := n <= 1; got != want {
+			t.Errorf("minremovals(%v, 1) <= 1 is %v, brute force says %v", level, got, want)
+		}
+		if n <= 1 {
+			if len(removed) != n {
+				t.Errorf("minremovals(%v, 1) removed %v, want %d levels", level, removed, n)
+			}
+			kept := slices.Clone(level)
+			for i := len(removed) - 1; i >= 0; i-- {
+				kept = slices.Delete(kept, removed[i], removed[i]+1)
+			}
+			if !safe(kept, DefaultRule) {
+				t.Errorf("minremovals(%v, 1) removed %v, leaving unsafe %v", level, removed, kept)
+			}
+		}
+	}
+}
+
+func Test_solve2(t *testing.T) {
+	type args struct {
+		input string
+		opts  Options
+	}
+	input := `7 6 4 2 1
+			1 2 7 8 9
+			9 7 6 2 1
+			1 3 2 4 5
+			8 6 4 4 1
+			1 3 6 7 9`
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{name: "no tolerance", args: args{input: input, opts: Options{Rule: DefaultRule, Tolerance: 0}}, want: "2"},
+		{name: "one level", args: args{input: input, opts: Options{Rule: DefaultRule, Tolerance: 1}}, want: "4"},
+		{name: "two levels", args: args{input: input, opts: Options{Rule: DefaultRule, Tolerance: 2}}, want: "6"},
+		{
+			name: "plateaus allowed",
+			args: args{input: input, opts: Options{Rule: Rule{MinStep: 1, MaxStep: 3, AllowPlateaus: true}, Tolerance: 0}},
+			want: "3",
+		},
+		{
+			name: "descending only",
+			args: args{input: input, opts: Options{Rule: Rule{MinStep: 1, MaxStep: 3, Direction: Descending}, Tolerance: 1}},
+			want: "2",
+		},
+		{
+			name: "wider steps",
+			args: args{input: input, opts: Options{Rule: Rule{MinStep: 1, MaxStep: 5}, Tolerance: 0}},
+			want: "4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := solve2(tt.args.input, tt.args.opts); got != tt.want {
+				t.Errorf("solve2() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_explain(t *testing.T) {
+	type args struct {
+		input string
+		opts  Options
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "test input",
+			args: args{
+				input: `7 6 4 2 1
+						1 2 7 8 9
+						9 7 6 2 1
+						1 3 2 4 5
+						8 6 4 4 1
+						1 3 6 7 9`,
+				opts: DefaultOptions,
+			},
+			want: `[7 6 4 2 1]: safe
+[1 2 7 8 9]: unsafe; levels 1-2 (2 -> 7): step too large
+[9 7 6 2 1]: unsafe; levels 2-3 (6 -> 2): step too large
+[1 3 2 4 5]: safe after removing level 1 (3); levels 1-2 (3 -> 2): direction change
+[8 6 4 4 1]: safe after removing level 2 (4); levels 2-3 (4 -> 4): equal levels
+[1 3 6 7 9]: safe
+`,
+		},
+		{
+			name: "two levels tolerated",
+			args: args{
+				input: `7 6 4 2 1
+						1 2 7 8 9
+						9 7 6 2 1
+						1 3 2 4 5`,
+				opts: Options{Rule: DefaultRule, Tolerance: 2},
+			},
+			want: `[7 6 4 2 1]: safe
+[1 2 7 8 9]: safe after removing levels 0 (1), 1 (2); levels 1-2 (2 -> 7): step too large
+[9 7 6 2 1]: safe after removing levels 3 (2), 4 (1); levels 2-3 (6 -> 2): step too large
+[1 3 2 4 5]: safe after removing level 1 (3); levels 1-2 (3 -> 2): direction change
+`,
+		},
+		{
+			name: "custom rule",
+			args: args{
+				input: `1 2 3
+						5 4 3
+						1 3 5`,
+				opts: Options{Rule: Rule{MinStep: 2, MaxStep: 3, Direction: Ascending}},
+			},
+			want: `[1 2 3]: unsafe; levels 0-1 (1 -> 2): step too small
+[5 4 3]: unsafe; levels 0-1 (5 -> 4): wrong direction
+[1 3 5]: safe
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := explain(tt.args.input, tt.args.opts); got != tt.want {
+				t.Errorf("explain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_main(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// MAX_DIGITS is the longest number the puzzle allows as an argument.
+const MAX_DIGITS = 3
+
+// Token is a well-formed instruction found in corrupted memory, starting at
+// byte Offset of the input.
+type Token struct {
+	Name   string
+	Args   []int
+	Offset int
+}
+
+// lexer finds the instructions of an InstructionSet in a byte stream. An
+// instruction is its name, '(', Arity comma-separated numbers of 1 to
+// MAX_DIGITS digits and ')', with nothing else in between.
+type lexer struct {
+	// prefixes holds each instruction's name followed by '(', indexed by
+	// its first byte so most positions are rejected with one lookup.
+	prefixes [256][][]byte
+	arity    map[string]int
+}
+
+func newlexer(set InstructionSet) *lexer {
+	l := &lexer{arity: map[string]int{}}
+	for name, instr := range set {
+		prefix := []byte(name + "(")
+		l.prefixes[prefix[0]] = append(l.prefixes[prefix[0]], prefix)
+		l.arity[name] = instr.Arity
+	}
+	for _, prefixes := range l.prefixes {
+		slices.SortFunc(prefixes, func(a, b []byte) int {
+			return cmp.Or(cmp.Compare(len(b), len(a)), bytes.Compare(a, b))
+		})
+	}
+	return l
+}
+
+func isdigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+type lexstatus int
+
+const (
+	NOMATCH lexstatus = iota
+	MATCH
+	// NEEDMORE means buf ended before an instruction could be accepted or
+	// rejected; the caller should retry once more input has been appended.
+	NEEDMORE
+)
+
+// lexat reads the instruction starting at buf[i], if there is one, and
+// returns it with its length in bytes. If eof is false, buf may be followed
+// by more input, so running off its end yields NEEDMORE rather than NOMATCH.
+func (l *lexer) lexat(buf []byte, i int, eof bool) (Token, int, lexstatus) {
+	short := NOMATCH
+	if !eof {
+		short = NEEDMORE
+	}
+	for _, prefix := range l.prefixes[buf[i]] {
+		if !bytes.HasPrefix(buf[i:], prefix) {
+			if bytes.HasPrefix(prefix, buf[i:]) {
+				return Token{}, 0, short
+			}
+			continue
+		}
+		name := string(prefix[:len(prefix)-1])
+		arity := l.arity[name]
+		j := i + len(prefix)
+		args := make([]int, 0, arity)
+		for a := 0; a < arity; a++ {
+			if a > 0 {
+				if j >= len(buf) {
+					return Token{}, 0, short
+				}
+				if buf[j] != ',' {
+					return Token{}, 0, NOMATCH
+				}
+				j++
+			}
+			start, n := j, 0
+			for j < len(buf) && j-start < MAX_DIGITS && isdigit(buf[j]) {
+				n = n*10 + int(buf[j]-'0')
+				j++
+			}
+			if j >= len(buf) {
+				return Token{}, 0, short
+			}
+			if j == start {
+				return Token{}, 0, NOMATCH
+			}
+			args = append(args, n)
+		}
+		if j >= len(buf) {
+			return Token{}, 0, short
+		}
+		if buf[j] != ')' {
+			return Token{}, 0, NOMATCH
+		}
+		return Token{Name: name, Args: args, Offset: i}, j + 1 - i, MATCH
+	}
+	return Token{}, 0, NOMATCH
+}
+
+// lex scans buf once, returning every instruction in order. Like a regexp
+// search, scanning resumes after the end of each instruction found.
+func (l *lexer) lex(buf []byte) []Token {
+	var tokens []Token
+	for i := 0; i < len(buf); {
+		if tok, n, status := l.lexat(buf, i, true); status == MATCH {
+			tokens = append(tokens, tok)
+			i += n
+		} else {
+			i++
+		}
+	}
+	return tokens
+}
+
+// maxlen is the length of the longest instruction the lexer can match.
+func (l *lexer) maxlen() int {
+	longest := 0
+	for name, arity := range l.arity {
+		n := len(name) + 2 + arity*MAX_DIGITS
+		if arity > 1 {
+			n += arity - 1
+		}
+		longest = max(longest, n)
+	}
+	return longest
+}
+
+// Scanner reads instructions from an io.Reader in fixed-size chunks. Only the
+// tail of a chunk that could still begin an instruction is carried over to
+// the next, so memory use is bounded by the chunk size whatever the input
+// size. Offsets in the returned tokens are relative to the whole stream.
+type Scanner struct {
+	r    io.Reader
+	lex  *lexer
+	buf  []byte
+	pos  int
+	base int
+	eof  bool
+	tok  Token
+	err  error
+}
+
+func newscanner(r io.Reader, set InstructionSet, chunksize int) *Scanner {
+	lex := newlexer(set)
+	return &Scanner{
+		r:   r,
+		lex: lex,
+		buf: make([]byte, 0, max(chunksize, 1)+lex.maxlen()),
+	}
+}
+
+// Scan advances to the next instruction, returning false at the end of the
+// input or on a read error.
+func (s *Scanner) Scan() bool {
+	for {
+		for s.pos < len(s.buf) {
+			tok, n, status := s.lex.lexat(s.buf, s.pos, s.eof)
+			if status == NEEDMORE {
+				break
+			}
+			if status == MATCH {
+				tok.Offset += s.base
+				s.tok = tok
+				s.pos += n
+				return true
+			}
+			s.pos++
+		}
+		if s.eof || s.err != nil {
+			return false
+		}
+		s.base += s.pos
+		s.buf = s.buf[:copy(s.buf, s.buf[s.pos:])]
+		s.pos = 0
+		n, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+n]
+		if errors.Is(err, io.EOF) {
+			s.eof = true
+		} else if err != nil {
+			s.err = err
+			return false
+		}
+	}
+}
+
+func (s *Scanner) Token() Token {
+	return s.tok
+}
+
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Rejection is a corrupted instruction that almost parsed: an instruction
+// name followed by '(' whose arguments or spacing break the rules. Text is
+// the input from Offset up to and including the offending byte.
+type Rejection struct {
+	Offset int
+	Text   string
+	Reason string
+}
+
+func isspace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// reject explains why the instruction starting at buf[i] does not parse. It
+// returns false if buf[i] does not start an instruction name followed by an
+// opening parenthesis, or if the instruction is in fact well-formed.
+func (l *lexer) reject(buf []byte, i int) (Rejection, bool) {
+	name := ""
+	for _, prefix := range l.prefixes[buf[i]] {
+		if bytes.HasPrefix(buf[i:], prefix[:len(prefix)-1]) {
+			name = string(prefix[:len(prefix)-1])
+			break
+		}
+	}
+	if name == "" {
+		return Rejection{}, false
+	}
+	fail := func(end int, reason string) (Rejection, bool) {
+		end = min(end, len(buf))
+		return Rejection{Offset: i, Text: string(buf[i:end]), Reason: reason}, true
+	}
+	unexpected := func(j int) (Rejection, bool) {
+		if j >= len(buf) {
+			return fail(j, "unterminated instruction")
+		}
+		if isspace(buf[j]) {
+			return fail(j+1, "whitespace in arguments")
+		}
+		return fail(j+1, fmt.Sprintf("unexpected %q", buf[j]))
+	}
+
+	j := i + len(name)
+	k := j
+	for k < len(buf) && isspace(buf[k]) {
+		k++
+	}
+	if k >= len(buf) || buf[k] != '(' {
+		return Rejection{}, false
+	}
+	if k > j {
+		return fail(k+1, "whitespace before '('")
+	}
+	j = k + 1
+	arity := l.arity[name]
+	for a := 0; a < arity; a++ {
+		if a > 0 {
+			if j < len(buf) && buf[j] == ')' {
+				return fail(j+1, fmt.Sprintf("expected %d arguments, got %d", arity, a))
+			}
+			if j >= len(buf) || buf[j] != ',' {
+				return unexpected(j)
+			}
+			j++
+		}
+		start := j
+		for j < len(buf) && isdigit(buf[j]) {
+			j++
+		}
+		if j-start > MAX_DIGITS {
+			return fail(j, fmt.Sprintf("number longer than %d digits", MAX_DIGITS))
+		}
+		if j == start {
+			if j < len(buf) && buf[j] == ')' {
+				return fail(j+1, fmt.Sprintf("expected %d arguments, got %d", arity, a))
+			}
+			return unexpected(j)
+		}
+	}
+	if j < len(buf) && buf[j] == ',' {
+		return fail(j+1, "too many arguments")
+	}
+	if j >= len(buf) || buf[j] != ')' {
+		return unexpected(j)
+	}
+	return Rejection{}, false
+}
+
+// audit scans buf like lex, also collecting the near-miss instructions it
+// passes over.
+func (l *lexer) audit(buf []byte) ([]Token, []Rejection) {
+	var tokens []Token
+	var rejections []Rejection
+	for i := 0; i < len(buf); {
+		if tok, n, status := l.lexat(buf, i, true); status == MATCH {
+			tokens = append(tokens, tok)
+			i += n
+			continue
+		}
+		if r, ok := l.reject(buf, i); ok {
+			rejections = append(rejections, r)
+		}
+		i++
+	}
+	return tokens, rejections
+}
@@ -1,66 +1,146 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"regexp"
-	"strconv"
+	"strings"
 )
 
-func part1(input string) string {
-	exp := regexp.MustCompile(`mul\(([0-9]+),([0-9]+)\)`)
-	matches := exp.FindAllStringSubmatch(input, -1)
-	ans := 0
-	for _, match := range matches {
-		a, err := strconv.Atoi(match[1])
-		if err != nil {
-			log.Fatal(err)
+// Machine is the state the corrupted-memory program runs against.
+type Machine struct {
+	Enabled bool
+	Sum     int
+}
+
+type Instruction struct {
+	Arity int
+	Exec  func(m *Machine, args []int)
+}
+
+// InstructionSet maps instruction names to their handlers. Adding an
+// instruction only needs a new entry; the lexer picks it up from the set.
+type InstructionSet map[string]Instruction
+
+func mul(m *Machine, args []int) {
+	m.Sum += args[0] * args[1]
+}
+
+func mulenabled(m *Machine, args []int) {
+	if m.Enabled {
+		mul(m, args)
+	}
+}
+
+func enable(m *Machine, args []int) {
+	m.Enabled = true
+}
+
+func disable(m *Machine, args []int) {
+	m.Enabled = false
+}
+
+var PART1_INSTRUCTIONS = InstructionSet{
+	"mul": {Arity: 2, Exec: mul},
+}
+
+var PART2_INSTRUCTIONS = InstructionSet{
+	"mul":   {Arity: 2, Exec: mulenabled},
+	"do":    {Arity: 0, Exec: enable},
+	"don't": {Arity: 0, Exec: disable},
+}
+
+func execute(tokens []Token, set InstructionSet) int {
+	m := &Machine{Enabled: true}
+	for _, tok := range tokens {
+		set[tok.Name].Exec(m, tok.Args)
+	}
+	return m.Sum
+}
+
+func interpret(input string, set InstructionSet) int {
+	return execute(newlexer(set).lex([]byte(input)), set)
+}
+
+// interpretreader runs the program in r chunk by chunk, carrying the machine
+// state (including whether mul is enabled) across chunk boundaries.
+func interpretreader(r io.Reader, set InstructionSet, chunksize int) (int, error) {
+	m := &Machine{Enabled: true}
+	scanner := newscanner(r, set, chunksize)
+	for scanner.Scan() {
+		tok := scanner.Token()
+		set[tok.Name].Exec(m, tok.Args)
+	}
+	return m.Sum, scanner.Err()
+}
+
+// auditreport lists the near-miss instructions in input with their offsets
+// and reasons, then how many bytes belong to valid instructions, near misses
+// and plain noise.
+func auditreport(input string, set InstructionSet) string {
+	buf := []byte(input)
+	lex := newlexer(set)
+	tokens, rejections := lex.audit(buf)
+
+	var sb strings.Builder
+	const (
+		NOISE = iota
+		NEARMISS
+		VALID
+	)
+	kind := make([]int, len(buf))
+	for _, r := range rejections {
+		fmt.Fprintf(&sb, "%d: %q: %s\n", r.Offset, r.Text, r.Reason)
+		for i := r.Offset; i < r.Offset+len(r.Text); i++ {
+			kind[i] = max(kind[i], NEARMISS)
 		}
-		b, err := strconv.Atoi(match[2])
-		if err != nil {
-			log.Fatal(err)
+	}
+	for _, tok := range tokens {
+		_, n, _ := lex.lexat(buf, tok.Offset, true)
+		for i := tok.Offset; i < tok.Offset+n; i++ {
+			kind[i] = VALID
 		}
-		ans += a * b
 	}
-	return fmt.Sprint(ans)
+	var counts [3]int
+	for _, k := range kind {
+		counts[k]++
+	}
+	fmt.Fprintf(&sb, "%d instructions (%d bytes), %d near misses (%d bytes), %d bytes of noise\n",
+		len(tokens), counts[VALID], len(rejections), counts[NEARMISS], counts[NOISE])
+	return sb.String()
+}
+
+func part1(input string) string {
+	return fmt.Sprint(interpret(input, PART1_INSTRUCTIONS))
 }
 
 func part2(input string) string {
-	mul := regexp.MustCompile(`mul\(([0-9]+),([0-9]+)\)`)
-	do := regexp.MustCompile(`do\(\)`)
-	dont := regexp.MustCompile(`don't\(\)`)
-	anymatch := regexp.MustCompile(`(mul\(([0-9]+),([0-9]+)\)|do\(\)|don't\(\))`)
-	matches := anymatch.FindAllStringSubmatch(input, -1)
-	enabled := true
-	ans := 0
-	for _, match := range matches {
-		if mul.MatchString(match[0]) {
-			if !enabled {
-				continue
-			}
-			a, err := strconv.Atoi(match[2])
+	return fmt.Sprint(interpret(input, PART2_INSTRUCTIONS))
+}
+
+func main() {
+	stream := flag.Bool("stream", false, "read the memory dump in chunks instead of loading it whole")
+	chunksize := flag.Int("chunk", 1<<16, "bytes read at a time with -stream")
+	audit := flag.Bool("audit", false, "list corrupted instructions that almost parsed")
+	flag.Parse()
+
+	if *stream {
+		for i, set := range []InstructionSet{PART1_INSTRUCTIONS, PART2_INSTRUCTIONS} {
+			f, err := os.Open("../data/day03.txt")
 			if err != nil {
 				log.Fatal(err)
 			}
-			b, err := strconv.Atoi(match[3])
+			ans, err := interpretreader(f, set, *chunksize)
+			f.Close()
 			if err != nil {
 				log.Fatal(err)
 			}
-			ans += a * b
-		} else if do.MatchString(match[0]) {
-			enabled = true
-		} else if dont.MatchString(match[0]) {
-			enabled = false
-		} else {
-			log.Fatal("Should be unreachable. Reached match ", match[0])
+			fmt.Printf("Part %d: %d\n", i+1, ans)
 		}
+		return
 	}
-	return fmt.Sprint(ans)
-}
-
-func main() {
 	f, err := os.Open("../data/day03.txt")
 	if err != nil {
 		log.Fatal(err)
@@ -70,6 +150,10 @@ func main() {
 		log.Fatal(err)
 	}
 	s := string(b)
+	if *audit {
+		fmt.Print(auditreport(s, PART2_INSTRUCTIONS))
+		return
+	}
 	fmt.Printf("Part 1: %s\n", part1(s))
 	fmt.Printf("Part 2: %s\n", part2(s))
 }
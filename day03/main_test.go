@@ -1,7 +1,12 @@
 package main
 
 import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func Test_part1(t *testing.T) {
@@ -55,3 +60,210 @@ func Test_part2(t *testing.T) {
 		})
 	}
 }
+
+func Test_lex(t *testing.T) {
+	type args struct {
+		input string
+		set   InstructionSet
+	}
+	tests := []struct {
+		name string
+		args args
+		want []Token
+	}{
+		{
+			name: "part 2 test input",
+			args: args{
+				input: `xmul(2,4)&mul[3,7]!^don't()_mul(5,5)+mul(32,64](mul(11,8)undo()?mul(8,5))`,
+				set:   PART2_INSTRUCTIONS,
+			},
+			want: []Token{
+				{Name: "mul", Args: []int{2, 4}, Offset: 1},
+				{Name: "don't", Args: []int{}, Offset: 20},
+				{Name: "mul", Args: []int{5, 5}, Offset: 28},
+				{Name: "mul", Args: []int{11, 8}, Offset: 48},
+				{Name: "do", Args: []int{}, Offset: 59},
+				{Name: "mul", Args: []int{8, 5}, Offset: 64},
+			},
+		},
+		{
+			name: "restarts inside a failed instruction",
+			args: args{
+				input: "mul(mul(1,2)mul(1234,5)mul(123,4)",
+				set:   PART1_INSTRUCTIONS,
+			},
+			want: []Token{
+				{Name: "mul", Args: []int{1, 2}, Offset: 4},
+				{Name: "mul", Args: []int{123, 4}, Offset: 23},
+			},
+		},
+		{
+			name: "no instructions",
+			args: args{
+				input: "mul[1,2]",
+				set:   PART1_INSTRUCTIONS,
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newlexer(tt.args.set).lex([]byte(tt.args.input)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_interpret(t *testing.T) {
+	add := func(m *Machine, args []int) {
+		if m.Enabled {
+			m.Sum += args[0] + args[1] + args[2]
+		}
+	}
+	set := InstructionSet{
+		"add":   {Arity: 3, Exec: add},
+		"mul":   PART2_INSTRUCTIONS["mul"],
+		"do":    PART2_INSTRUCTIONS["do"],
+		"don't": PART2_INSTRUCTIONS["don't"],
+	}
+	type args struct {
+		input string
+		set   InstructionSet
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "extra instruction",
+			args: args{
+				input: "add(1,2,3)mul(2,3)don't()add(1,1,1)do()add(4,5)add(7,8,9)",
+				set:   set,
+			},
+			want: 36,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interpret(tt.args.input, tt.args.set); got != tt.want {
+				t.Errorf("interpret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func randommemory(n int) string {
+	r := rand.New(rand.NewSource(int64(n)))
+	pieces := []string{"mul(", "do()", "don't()", "mul(12,3)", "mul(1234,5)", ",", ")", "(", "7", "45", "x", "don", "mu", "l"}
+	var sb strings.Builder
+	for range n {
+		sb.WriteString(pieces[r.Intn(len(pieces))])
+	}
+	return sb.String()
+}
+
+func Test_interpretreader(t *testing.T) {
+	inputs := []string{
+		`xmul(2,4)%&mul[3,7]!@^do_not_mul(5,5)+mul(32,64]then(mul(11,8)mul(8,5))`,
+		`xmul(2,4)&mul[3,7]!^don't()_mul(5,5)+mul(32,64](mul(11,8)undo()?mul(8,5))`,
+		randommemory(2000),
+	}
+	for i, input := range inputs {
+		for _, set := range []InstructionSet{PART1_INSTRUCTIONS, PART2_INSTRUCTIONS} {
+			want := interpret(input, set)
+			for _, chunksize := range []int{1, 2, 3, 5, 8, 13, 4096} {
+				t.Run(fmt.Sprintf("input %d chunk %d", i, chunksize), func(t *testing.T) {
+					got, err := interpretreader(strings.NewReader(input), set, chunksize)
+					if err != nil {
+						t.Fatalf("interpretreader() error = %v", err)
+					}
+					if got != want {
+						t.Errorf("interpretreader() = %v, want %v", got, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func Test_Scanner(t *testing.T) {
+	input := randommemory(500)
+	want := newlexer(PART2_INSTRUCTIONS).lex([]byte(input))
+	var got []Token
+	scanner := newscanner(iotest.HalfReader(strings.NewReader(input)), PART2_INSTRUCTIONS, 7)
+	for scanner.Scan() {
+		got = append(got, scanner.Token())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Scan() tokens = %v, want %v", got, want)
+	}
+	if cap(scanner.buf) != 7+newlexer(PART2_INSTRUCTIONS).maxlen() {
+		t.Errorf("Scan() grew its buffer to %d bytes", cap(scanner.buf))
+	}
+
+	scanner = newscanner(iotest.ErrReader(iotest.ErrTimeout), PART1_INSTRUCTIONS, 7)
+	if scanner.Scan() || scanner.Err() != iotest.ErrTimeout {
+		t.Errorf("Scan() on failing reader: err = %v, want %v", scanner.Err(), iotest.ErrTimeout)
+	}
+}
+
+func Test_audit(t *testing.T) {
+	type args struct {
+		input string
+		set   InstructionSet
+	}
+	tests := []struct {
+		name string
+		args args
+		want []Rejection
+	}{
+		{
+			name: "near misses",
+			args: args{
+				input: "mul(4*mul ( 2 , 4 )mul(1234,5)mul(2, 4)mul(1)mul(1,2,3)mul(2,3)do(1)don't(",
+				set:   PART2_INSTRUCTIONS,
+			},
+			want: []Rejection{
+				{Offset: 0, Text: "mul(4*", Reason: "unexpected '*'"},
+				{Offset: 6, Text: "mul (", Reason: "whitespace before '('"},
+				{Offset: 19, Text: "mul(1234", Reason: "number longer than 3 digits"},
+				{Offset: 30, Text: "mul(2, ", Reason: "whitespace in arguments"},
+				{Offset: 39, Text: "mul(1)", Reason: "expected 2 arguments, got 1"},
+				{Offset: 45, Text: "mul(1,2,", Reason: "too many arguments"},
+				{Offset: 63, Text: "do(1", Reason: "unexpected '1'"},
+				{Offset: 68, Text: "don't(", Reason: "unterminated instruction"},
+			},
+		},
+		{
+			name: "bare names are noise",
+			args: args{
+				input: "do_not_mul[3,7]mul(2,4)",
+				set:   PART2_INSTRUCTIONS,
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got := newlexer(tt.args.set).audit([]byte(tt.args.input))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("audit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_auditreport(t *testing.T) {
+	got := auditreport("xmul(2,4)mul(4*don't()", PART2_INSTRUCTIONS)
+	want := `9: "mul(4*": unexpected '*'
+2 instructions (15 bytes), 1 near misses (6 bytes), 1 bytes of noise
+`
+	if got != want {
+		t.Errorf("auditreport() = %v, want %v", got, want)
+	}
+}
@@ -1,6 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -66,3 +71,259 @@ func Test_part2(t *testing.T) {
 		})
 	}
 }
+
+func Test_search(t *testing.T) {
+	type args struct {
+		grid  []string
+		words []string
+		opts  SearchOptions
+	}
+	grid := []string{
+		"CATS",
+		"OXAD",
+		"GOTA",
+	}
+	tests := []struct {
+		name string
+		args args
+		want []Match
+	}{
+		{
+			name: "several words",
+			args: args{
+				grid:  grid,
+				words: []string{"CAT", "CATS", "DOG", "TXC"},
+			},
+			want: []Match{
+				{Word: "CAT", X: 0, Y: 0, Dir: Vector{1, 0}},
+				{Word: "CATS", X: 0, Y: 0, Dir: Vector{1, 0}},
+				{Word: "TXC", X: 2, Y: 2, Dir: Vector{-1, -1}},
+			},
+		},
+		{
+			name: "orthogonal only",
+			args: args{
+				grid:  grid,
+				words: []string{"COG", "TXC", "TAT"},
+				opts:  SearchOptions{Mode: ORTHOGONAL},
+			},
+			want: []Match{
+				{Word: "COG", X: 0, Y: 0, Dir: Vector{0, 1}},
+				{Word: "TAT", X: 2, Y: 0, Dir: Vector{0, 1}},
+				{Word: "TAT", X: 2, Y: 2, Dir: Vector{0, -1}},
+			},
+		},
+		{
+			name: "diagonal only",
+			args: args{
+				grid:  grid,
+				words: []string{"COG", "TXC"},
+				opts:  SearchOptions{Mode: DIAGONAL},
+			},
+			want: []Match{
+				{Word: "TXC", X: 2, Y: 2, Dir: Vector{-1, -1}},
+			},
+		},
+		{
+			name: "wrap around",
+			args: args{
+				grid:  grid,
+				words: []string{"SCA", "GOC"},
+				opts:  SearchOptions{Wrap: true, Mode: ORTHOGONAL},
+			},
+			want: []Match{
+				{Word: "SCA", X: 3, Y: 0, Dir: Vector{1, 0}},
+				{Word: "GOC", X: 0, Y: 2, Dir: Vector{0, -1}},
+			},
+		},
+		{
+			name: "single letters",
+			args: args{
+				grid:  grid,
+				words: []string{"X"},
+			},
+			want: []Match{
+				{Word: "X", X: 1, Y: 1, Dir: Vector{-1, -1}},
+				{Word: "X", X: 1, Y: 1, Dir: Vector{-1, 0}},
+				{Word: "X", X: 1, Y: 1, Dir: Vector{-1, 1}},
+				{Word: "X", X: 1, Y: 1, Dir: Vector{0, -1}},
+				{Word: "X", X: 1, Y: 1, Dir: Vector{0, 1}},
+				{Word: "X", X: 1, Y: 1, Dir: Vector{1, -1}},
+				{Word: "X", X: 1, Y: 1, Dir: Vector{1, 0}},
+				{Word: "X", X: 1, Y: 1, Dir: Vector{1, 1}},
+			},
+		},
+		{
+			name: "wrap across an empty row",
+			args: args{
+				grid:  []string{"CAT", "", "CAT"},
+				words: []string{"CAT", "CC"},
+				opts:  SearchOptions{Wrap: true, Mode: ORTHOGONAL},
+			},
+			want: []Match{
+				{Word: "CC", X: 0, Y: 0, Dir: Vector{0, -1}},
+				{Word: "CAT", X: 0, Y: 0, Dir: Vector{1, 0}},
+				{Word: "CC", X: 0, Y: 2, Dir: Vector{0, 1}},
+				{Word: "CAT", X: 0, Y: 2, Dir: Vector{1, 0}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := search(tt.args.grid, tt.args.words, tt.args.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parsepattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Pattern
+		wanterr bool
+	}{
+		{name: "slashes", input: "M.S / .A. / M.S", want: Pattern{"M.S", ".A.", "M.S"}},
+		{name: "newlines", input: "\n  .M.\n  MAS\n  .S.\n", want: Pattern{".M.", "MAS", ".S."}},
+		{name: "ragged", input: "MA / S", wanterr: true},
+		{name: "empty", input: " / ", wanterr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsepattern(tt.input)
+			if (err != nil) != tt.wanterr {
+				t.Fatalf("parsepattern() error = %v, wanterr %v", err, tt.wanterr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsepattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_variants(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern Pattern
+		want    int
+	}{
+		{name: "x-mas", pattern: XMAS_PATTERN, want: 4},
+		{name: "plus mas", pattern: mustparsepattern(".M. / MAS / .S."), want: 4},
+		{name: "magic square", pattern: mustparsepattern("276 / 951 / 438"), want: 8},
+		{name: "line", pattern: mustparsepattern("XMAS"), want: 4},
+		{name: "symmetric", pattern: mustparsepattern("A"), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(tt.pattern.variants()); got != tt.want {
+				t.Errorf("len(variants()) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_countpattern(t *testing.T) {
+	type args struct {
+		grid    []string
+		pattern Pattern
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "x-mas",
+			args: args{grid: parse(TEST_INPUT), pattern: XMAS_PATTERN},
+			want: 9,
+		},
+		{
+			name: "xmas as a pattern finds the orthogonal words",
+			args: args{grid: parse(TEST_INPUT), pattern: mustparsepattern("XMAS")},
+			want: 8,
+		},
+		{
+			name: "magic squares",
+			args: args{
+				grid:    []string{"8167", "3572", "4923", "9999"},
+				pattern: mustparsepattern("276 / 951 / 438"),
+			},
+			want: 1,
+		},
+		{
+			name: "plus mas",
+			args: args{
+				grid:    []string{".S..", "MAS.", ".M..", "...."},
+				pattern: mustparsepattern(".M. / MAS / .S."),
+			},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countpattern(tt.args.grid, tt.args.pattern); got != tt.want {
+				t.Errorf("countpattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func randomgrid(w int, h int, letters string, seed int64) []string {
+	r := rand.New(rand.NewSource(seed))
+	grid := make([]string, h)
+	for y := range grid {
+		var sb strings.Builder
+		for range w {
+			sb.WriteByte(letters[r.Intn(len(letters))])
+		}
+		grid[y] = sb.String()
+	}
+	return grid
+}
+
+func Test_countparallel(t *testing.T) {
+	type args struct {
+		grid []string
+		word string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{name: "test input", args: args{grid: parse(TEST_INPUT), word: "XMAS"}},
+		{name: "wide", args: args{grid: randomgrid(53, 17, "XMAS", 1), word: "XMAS"}},
+		{name: "tall", args: args{grid: randomgrid(9, 61, "XMAS", 2), word: "XMAS"}},
+		{name: "palindrome", args: args{grid: randomgrid(20, 20, "AB", 3), word: "ABA"}},
+		{name: "single letter", args: args{grid: randomgrid(7, 5, "XY", 4), word: "X"}},
+		{name: "longer than the grid", args: args{grid: randomgrid(3, 3, "A", 5), word: "AAAA"}},
+	}
+	for _, tt := range tests {
+		want := len(search(tt.args.grid, []string{tt.args.word}, SearchOptions{}))
+		for _, workers := range []int{1, 2, 3, 7, 100} {
+			t.Run(fmt.Sprintf("%s/%d workers", tt.name, workers), func(t *testing.T) {
+				got, err := countparallel(tt.args.grid, tt.args.word, workers)
+				if err != nil {
+					t.Fatalf("countparallel() error = %v", err)
+				}
+				if got != want {
+					t.Errorf("countparallel() = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCount(b *testing.B) {
+	grid := randomgrid(2000, 2000, "XMAS", 6)
+	b.Run("search", func(b *testing.B) {
+		for range b.N {
+			search(grid, []string{"XMAS"}, SearchOptions{})
+		}
+	})
+	b.Run("countparallel", func(b *testing.B) {
+		for range b.N {
+			countparallel(grid, "XMAS", runtime.NumCPU())
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// WILDCARD matches any letter in a Pattern.
+const WILDCARD = '.'
+
+// Pattern is a rectangular 2D template, one string per row.
+type Pattern []string
+
+// parsepattern reads a template written one row at a time, separated by '/'
+// or newlines, e.g. "M.S / .A. / M.S". Spaces around rows are ignored.
+func parsepattern(s string) (Pattern, error) {
+	var p Pattern
+	for _, row := range strings.FieldsFunc(s, func(r rune) bool { return r == '/' || r == '\n' }) {
+		row = strings.TrimSpace(row)
+		if row == "" {
+			continue
+		}
+		if len(p) > 0 && len(row) != len(p[0]) {
+			return nil, fmt.Errorf("pattern %q: row %q has width %d, want %d", s, row, len(row), len(p[0]))
+		}
+		p = append(p, row)
+	}
+	if len(p) == 0 {
+		return nil, fmt.Errorf("pattern %q is empty", s)
+	}
+	return p, nil
+}
+
+func mustparsepattern(s string) Pattern {
+	p, err := parsepattern(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// rotate returns p turned 90 degrees clockwise.
+func (p Pattern) rotate() Pattern {
+	h, w := len(p), len(p[0])
+	rotated := make(Pattern, w)
+	for x := 0; x < w; x++ {
+		row := make([]byte, h)
+		for y := 0; y < h; y++ {
+			row[y] = p[h-1-y][x]
+		}
+		rotated[x] = string(row)
+	}
+	return rotated
+}
+
+// reflect returns p mirrored left to right.
+func (p Pattern) reflect() Pattern {
+	reflected := make(Pattern, len(p))
+	for y, row := range p {
+		b := []byte(row)
+		slices.Reverse(b)
+		reflected[y] = string(b)
+	}
+	return reflected
+}
+
+// variants returns the distinct rotations and reflections of p, starting with
+// p itself.
+func (p Pattern) variants() []Pattern {
+	var variants []Pattern
+	for _, q := range []Pattern{p, p.reflect()} {
+		for range 4 {
+			if !slices.ContainsFunc(variants, func(v Pattern) bool { return slices.Equal(v, q) }) {
+				variants = append(variants, q)
+			}
+			q = q.rotate()
+		}
+	}
+	return variants
+}
+
+// matchat reports whether p matches grid with its top-left corner at (x, y).
+func (p Pattern) matchat(grid []string, x int, y int) bool {
+	if y+len(p) > len(grid) {
+		return false
+	}
+	for dy, row := range p {
+		line := grid[y+dy]
+		if x+len(row) > len(line) {
+			return false
+		}
+		for dx := 0; dx < len(row); dx++ {
+			if row[dx] != WILDCARD && row[dx] != line[x+dx] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// countpattern counts the occurrences of p in grid in any rotation or
+// reflection. Each distinct variant matching at a position counts once.
+func countpattern(grid []string, p Pattern) int {
+	variants := p.variants()
+	n := 0
+	for y := range grid {
+		for x := range grid[y] {
+			for _, v := range variants {
+				if v.matchat(grid, x, y) {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"slices"
+	"sync"
+)
+
+// shiftand matches several patterns of equal length at once with the
+// bit-parallel Shift-And algorithm. The patterns are laid end to end in one
+// 64-bit state word, so their combined length must be at most 64.
+type shiftand struct {
+	masks  [256]uint64
+	starts uint64
+	ends   uint64
+	length int
+}
+
+func newshiftand(patterns []string) (*shiftand, error) {
+	m := &shiftand{length: len(patterns[0])}
+	bit := 0
+	for _, p := range patterns {
+		if len(p) != m.length || len(p) == 0 {
+			return nil, fmt.Errorf("patterns must be non-empty and of equal length, got %q", patterns)
+		}
+		if bit+len(p) > 64 {
+			return nil, fmt.Errorf("patterns %q are longer than 64 bytes in total", patterns)
+		}
+		m.starts |= 1 << bit
+		for i := 0; i < len(p); i++ {
+			m.masks[p[i]] |= 1 << (bit + i)
+		}
+		bit += len(p)
+		m.ends |= 1 << (bit - 1)
+	}
+	return m, nil
+}
+
+// count returns the number of pattern occurrences in line that start before
+// index maxstart.
+func (m *shiftand) count(line []byte, maxstart int) int {
+	if maxstart <= 0 {
+		return 0
+	}
+	n := 0
+	var d uint64
+	for _, c := range line[:min(len(line), maxstart+m.length-1)] {
+		d = ((d << 1) | m.starts) & m.masks[c]
+		if hit := d & m.ends; hit != 0 {
+			n += bits.OnesCount64(hit)
+		}
+	}
+	return n
+}
+
+// countband counts the occurrences of m's patterns along the rows, columns
+// and both diagonals of grid whose topmost letter lies in rows [y0, y1). The
+// columns and diagonals are cut off length-1 rows past the band, which is
+// enough to hold any occurrence starting inside it.
+func countband(grid []string, m *shiftand, y0 int, y1 int) int {
+	h, w := len(grid), len(grid[0])
+	ext := min(h, y1+m.length-1)
+	n := 0
+	for y := y0; y < y1; y++ {
+		n += m.count([]byte(grid[y]), w)
+	}
+
+	line := make([]byte, 0, ext-y0)
+	for x := 0; x < w; x++ {
+		line = line[:0]
+		for y := y0; y < ext; y++ {
+			line = append(line, grid[y][x])
+		}
+		n += m.count(line, y1-y0)
+	}
+	// Down-right diagonals, where x-y = d.
+	for d := -(h - 1); d < w; d++ {
+		top := max(y0, -d)
+		line = line[:0]
+		for y := top; y < min(ext, w-d); y++ {
+			line = append(line, grid[y][y+d])
+		}
+		n += m.count(line, y1-top)
+	}
+	// Down-left diagonals, where x+y = s.
+	for s := 0; s < w+h-1; s++ {
+		top := max(y0, s-(w-1))
+		line = line[:0]
+		for y := top; y < min(ext, s+1); y++ {
+			line = append(line, grid[y][s-y])
+		}
+		n += m.count(line, y1-top)
+	}
+	return n
+}
+
+// countparallel counts the occurrences of word in grid in all 8 directions,
+// like part1, by scanning every row, column and diagonal for the word and its
+// reverse. The rows are split into bands handled by separate goroutines.
+func countparallel(grid []string, word string, workers int) (int, error) {
+	reversed := []byte(word)
+	slices.Reverse(reversed)
+	m, err := newshiftand([]string{word, string(reversed)})
+	if err != nil {
+		return 0, err
+	}
+	h := len(grid)
+	if h == 0 {
+		return 0, nil
+	}
+	workers = max(1, min(workers, h))
+	band := (h + workers - 1) / workers
+	counts := make([]int, workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			y0 := i * band
+			counts[i] = countband(grid, m, min(y0, h), min(y0+band, h))
+		}()
+	}
+	wg.Wait()
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	return total, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+type Mode int
+
+const (
+	ALL Mode = iota
+	ORTHOGONAL
+	DIAGONAL
+)
+
+type SearchOptions struct {
+	// Wrap lets words run off one edge of the grid and continue from the
+	// opposite edge.
+	Wrap bool
+	Mode Mode
+}
+
+// Match is an occurrence of Word whose first letter is at (X, Y) and which
+// reads in direction Dir.
+type Match struct {
+	Word string
+	X    int
+	Y    int
+	Dir  Vector
+}
+
+type trie struct {
+	children map[byte]*trie
+	word     string
+	depth    int
+}
+
+func newtrie(words []string) *trie {
+	root := &trie{children: map[byte]*trie{}}
+	for _, word := range words {
+		node := root
+		for i := 0; i < len(word); i++ {
+			child, ok := node.children[word[i]]
+			if !ok {
+				child = &trie{children: map[byte]*trie{}}
+				node.children[word[i]] = child
+			}
+			node = child
+		}
+		node.word = word
+		root.depth = max(root.depth, len(word))
+	}
+	return root
+}
+
+func (o SearchOptions) dirs() []Vector {
+	var dirs []Vector
+	for _, dir := range DIRS {
+		diagonal := dir.X != 0 && dir.Y != 0
+		if o.Mode == ALL || (o.Mode == DIAGONAL) == diagonal {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// search finds every occurrence of words in grid, walking a trie of the
+// words outward from each cell so that all words are matched in one pass.
+// A single-letter word gives one Match per direction allowed by opts at each
+// matching cell, which is how part1 has always counted it.
+func search(grid []string, words []string, opts SearchOptions) []Match {
+	root := newtrie(words)
+	dirs := opts.dirs()
+	h := len(grid)
+	var matches []Match
+	for y := 0; y < h; y++ {
+		w := len(grid[y])
+		for x := 0; x < w; x++ {
+			start, ok := root.children[grid[y][x]]
+			if !ok {
+				continue
+			}
+			for _, dir := range dirs {
+				if start.word != "" {
+					matches = append(matches, Match{Word: start.word, X: x, Y: y, Dir: dir})
+				}
+				node := start
+				cx, cy := x, y
+				for i := 1; i < root.depth; i++ {
+					cx, cy = cx+dir.X, cy+dir.Y
+					if opts.Wrap {
+						cy = (cy%h + h) % h
+						// No word can run through an empty row.
+						if len(grid[cy]) == 0 {
+							break
+						}
+						cx = (cx%len(grid[cy]) + len(grid[cy])) % len(grid[cy])
+					} else if cy < 0 || cy >= h || cx < 0 || cx >= len(grid[cy]) {
+						break
+					}
+					node = node.children[grid[cy][cx]]
+					if node == nil {
+						break
+					}
+					if node.word != "" {
+						matches = append(matches, Match{Word: node.word, X: x, Y: y, Dir: dir})
+					}
+				}
+			}
+		}
+	}
+	return matches
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RuleIndex is a compiled form of the page-ordering rules for validating
+// many updates. Pages are interned to dense ids, and the rules are stored as
+// a bit matrix so that checking a pair of pages is a single bit test.
+type RuleIndex struct {
+	ids   map[int]int
+	words int
+	// before holds one row of words uint64s per page id; bit b of row a is
+	// set if page a must come before page b.
+	before []uint64
+}
+
+func compile(rules []Ordering) *RuleIndex {
+	ix := &RuleIndex{ids: map[int]int{}}
+	for _, rule := range rules {
+		for _, page := range []int{rule.a, rule.b} {
+			if _, ok := ix.ids[page]; !ok {
+				ix.ids[page] = len(ix.ids)
+			}
+		}
+	}
+	ix.words = (len(ix.ids) + 63) / 64
+	ix.before = make([]uint64, len(ix.ids)*ix.words)
+	for _, rule := range rules {
+		a, b := ix.ids[rule.a], ix.ids[rule.b]
+		ix.before[a*ix.words+b/64] |= 1 << (b % 64)
+	}
+	return ix
+}
+
+// intern maps the pages of seq to their ids. Pages that appear in no rule
+// get -1.
+func (ix *RuleIndex) intern(seq []int) []int {
+	ids := make([]int, len(seq))
+	for i, page := range seq {
+		id, ok := ix.ids[page]
+		if !ok {
+			id = -1
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+func (ix *RuleIndex) mustprecede(a int, b int) bool {
+	if a < 0 || b < 0 {
+		return false
+	}
+	return ix.before[a*ix.words+b/64]&(1<<(b%64)) != 0
+}
+
+// valid is validseq over interned ids.
+func (ix *RuleIndex) valid(ids []int) bool {
+	for i := 0; i < len(ids); i++ {
+		for j := 0; j < i; j++ {
+			if ix.mustprecede(ids[i], ids[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// order returns the positions of ids in sorted order. It runs Kahn's
+// algorithm with the in-degrees counted from the bit matrix, taking the
+// earliest ready page of the update first, like toposort.Graph.Sort. If the
+// rules form a cycle it stops and returns the positions on the cycle too, or
+// with TIEBREAK places the earliest remaining page and carries on, like
+// toposort.Graph.SortForcing.
+func (ix *RuleIndex) order(ids []int, policy CyclePolicy) (order []int, cycle []int) {
+	n := len(ids)
+	indeg := make([]int, n)
+	for i := range n {
+		for j := range n {
+			if ix.mustprecede(ids[j], ids[i]) {
+				indeg[i]++
+			}
+		}
+	}
+	placed := make([]bool, n)
+	order = make([]int, 0, n)
+	for len(order) < n {
+		next := -1
+		for i := range n {
+			if !placed[i] && indeg[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			next = slices.Index(placed, false)
+			if policy != TIEBREAK {
+				return order, ix.cycle(ids, placed, next)
+			}
+		}
+		placed[next] = true
+		order = append(order, next)
+		for j := range n {
+			if !placed[j] && ix.mustprecede(ids[next], ids[j]) {
+				indeg[j]--
+			}
+		}
+	}
+	return order, nil
+}
+
+// cycle follows unplaced predecessors back from position start until one
+// repeats, and returns the loop starting from its earliest position, like
+// toposort.Graph's cycle.
+func (ix *RuleIndex) cycle(ids []int, placed []bool, start int) []int {
+	seen := map[int]int{}
+	var path []int
+	for i := start; ; {
+		if k, ok := seen[i]; ok {
+			cycle := path[k:]
+			slices.Reverse(cycle)
+			first := slices.Index(cycle, slices.Min(cycle))
+			return slices.Concat(cycle[first:], cycle[:first])
+		}
+		seen[i] = len(path)
+		path = append(path, i)
+		for j := range ids {
+			if !placed[j] && ix.mustprecede(ids[j], ids[i]) {
+				i = j
+				break
+			}
+		}
+	}
+}
+
+// middle returns the middle page of seq once sorted, without building the
+// sorted update, or a *CycleError if the rules for seq form a cycle.
+func (ix *RuleIndex) middle(seq []int, ids []int, policy CyclePolicy) (int, error) {
+	if len(seq)%2 == 0 {
+		return 0, fmt.Errorf("update %v: middle page is undefined for an even number of pages", seq)
+	}
+	order, cycle := ix.order(ids, policy)
+	if cycle != nil {
+		pages := make([]int, len(cycle))
+		for i, pos := range cycle {
+			pages[i] = seq[pos]
+		}
+		return 0, &CycleError{Update: seq, Cycle: pages}
+	}
+	return seq[order[len(seq)/2]], nil
+}
+
+func parseupdate(line string) ([]int, error) {
+	var seq []int
+	for _, num := range strings.Split(line, ",") {
+		x, err := strconv.Atoi(num)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, x)
+	}
+	return seq, nil
+}
+
+// solvestream reads the rules from r, compiles them, then validates, sorts
+// and takes the middle pages of the updates that follow on workers
+// goroutines as they are read, handling rule cycles according to policy. It
+// returns the answers to both parts. If updates fail, the error is that of
+// the earliest one in the input, however the work was scheduled.
+func solvestream(r io.Reader, workers int, policy CyclePolicy) (int, int, error) {
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	var rules []Ordering
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			break
+		}
+		sides := strings.Split(line, "|")
+		if len(sides) != 2 {
+			return 0, 0, fmt.Errorf("bad rule %q", line)
+		}
+		a, err := strconv.Atoi(sides[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		b, err := strconv.Atoi(sides[1])
+		if err != nil {
+			return 0, 0, err
+		}
+		rules = append(rules, Ordering{a, b})
+	}
+	ix := compile(rules)
+
+	workers = max(workers, 1)
+	type update struct {
+		line int
+		seq  []int
+	}
+	// result holds a worker's sums and the error of the earliest update it
+	// failed on. Each worker receives updates in input order, so its first
+	// error is also its earliest.
+	type result struct {
+		part1   int
+		part2   int
+		errline int
+		err     error
+	}
+	updates := make(chan update, 64)
+	results := make(chan result, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var res result
+			for u := range updates {
+				if res.err != nil {
+					continue
+				}
+				seq := u.seq
+				ids := ix.intern(seq)
+				if ix.valid(ids) {
+					if len(seq)%2 == 0 {
+						res.errline = u.line
+						res.err = fmt.Errorf("update %v: middle page is undefined for an even number of pages", seq)
+						continue
+					}
+					res.part1 += seq[len(seq)/2]
+					continue
+				}
+				mid, err := ix.middle(seq, ids, policy)
+				if err != nil {
+					res.errline, res.err = u.line, err
+					continue
+				}
+				res.part2 += mid
+			}
+			results <- res
+		}()
+	}
+
+	var first result
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		seq, err := parseupdate(line)
+		if err != nil {
+			first.errline, first.err = lineno, fmt.Errorf("line %d: %w", lineno, err)
+			break
+		}
+		updates <- update{lineno, seq}
+	}
+	close(updates)
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	part1, part2 := 0, 0
+	for res := range results {
+		if res.err != nil && (first.err == nil || res.errline < first.errline) {
+			first.errline, first.err = res.errline, res.err
+		}
+		part1 += res.part1
+		part2 += res.part2
+	}
+	if first.err == nil {
+		first.err = scanner.Err()
+	}
+	return part1, part2, first.err
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+
+	"AdventOfCode2024/toposort"
 )
 
 type Ordering struct {
@@ -83,67 +87,67 @@ func part1(input string) string {
 	return fmt.Sprint(n)
 }
 
-func removevalue(a []int, x int) []int {
-	n := 0
-	for _, val := range a {
-		if val != x {
-			a[n] = val
-			n++
-		}
+// CyclePolicy says what sort does when the rules for an update contain a
+// cycle, so that no ordering satisfies all of them.
+type CyclePolicy int
+
+const (
+	// FAIL returns a *CycleError.
+	FAIL CyclePolicy = iota
+	// TIEBREAK places the earliest page of the update among those still
+	// waiting on a cycle, ignoring its remaining rules, and carries on.
+	TIEBREAK
+)
+
+type CycleError struct {
+	Update []int
+	// Cycle lists pages such that each must come before the next, and the
+	// last before the first.
+	Cycle []int
+}
+
+func (e *CycleError) Error() string {
+	pages := make([]string, len(e.Cycle)+1)
+	for i, page := range e.Cycle {
+		pages[i] = fmt.Sprint(page)
 	}
-	return a[:n]
+	pages[len(e.Cycle)] = pages[0]
+	return fmt.Sprintf("update %v: rules form a cycle %s", e.Update, strings.Join(pages, " -> "))
 }
 
-func sort(rules []Ordering, seq []int) []int {
+// graph builds the ordering graph for the pages of seq, adding them in seq
+// order so that ties are broken by their position in the update.
+func graph(rules []Ordering, seq []int) *toposort.Graph[int] {
 	members := make(map[int]bool)
+	g := toposort.New[int]()
 	for _, x := range seq {
 		members[x] = true
+		g.AddNode(x)
 	}
-	// map from Ordering.a to all Ordering.b values in the seq
-	after := make(map[int][]int)
-	before := make(map[int][]int)
 	for _, rule := range rules {
-		if !members[rule.a] || !members[rule.b] {
-			continue
+		if members[rule.a] && members[rule.b] {
+			g.AddEdge(rule.a, rule.b)
 		}
-		as := before[rule.b]
-		if as == nil {
-			as = []int{}
-		}
-		before[rule.b] = append(as, rule.a)
-		bs := after[rule.a]
-		if bs == nil {
-			bs = []int{}
-		}
-		after[rule.a] = append(bs, rule.b)
 	}
+	return g
+}
 
-	// List to contain sorted elements
-	sorted := []int{}
-	// Set of nodes with no inbound edges
-	S := []int{}
-	for _, x := range seq {
-		if before[x] == nil {
-			S = append(S, x)
-		}
-	}
-	// This is Kahn's Algorithm.
-	var node int
-	for len(S) > 0 {
-		node, S = S[0], S[1:]
-		sorted = append(sorted, node)
-		for _, m := range after[node] {
-			before[m] = removevalue(before[m], node)
-			if len(before[m]) == 0 {
-				S = append(S, m)
-			}
-		}
-		after[node] = nil
+func sort(rules []Ordering, seq []int, policy CyclePolicy) ([]int, error) {
+	g := graph(rules, seq)
+	if policy == TIEBREAK {
+		return g.SortForcing(), nil
 	}
-	return sorted
+	sorted, err := g.Sort()
+	var cycle *toposort.CycleError[int]
+	if errors.As(err, &cycle) {
+		return sorted, &CycleError{Update: seq, Cycle: cycle.Cycle}
+	}
+	return sorted, err
 }
 
-func part2(input string) string {
+// correct sums the middle pages of the invalid updates after sorting them,
+// handling rule cycles according to policy.
+func correct(input string, policy CyclePolicy) (int, error) {
 	rules, seqs := parse(input)
 	invalidorders := make(map[Ordering]bool)
 	for _, rule := range rules {
@@ -158,31 +162,134 @@ func part2(input string) string {
 
 	var sortedseqs [][]int
 	for _, seq := range invalidseqs {
-		sortedseqs = append(sortedseqs, sort(rules, seq))
+		sorted, err := sort(rules, seq, policy)
+		if err != nil {
+			return 0, err
+		}
+		sortedseqs = append(sortedseqs, sorted)
 	}
 
 	n := 0
 	for _, seq := range sortedseqs {
 		l := len(seq)
 		if l%2 == 0 {
-			log.Fatal("Middle page is undefined for an even number of pages.")
+			return 0, fmt.Errorf("update %v: middle page is undefined for an even number of pages", seq)
 		}
 		mid := l / 2
 		n += seq[mid]
 	}
+	return n, nil
+}
+
+func part2(input string) string {
+	n, err := correct(input, FAIL)
+	if err != nil {
+		log.Fatal(err)
+	}
 	return fmt.Sprint(n)
 }
 
+// uniqueness lists each corrected update and whether the rules allow only
+// that one order of its pages.
+func uniqueness(input string, policy CyclePolicy) (string, error) {
+	rules, seqs := parse(input)
+	invalidorders := make(map[Ordering]bool)
+	for _, rule := range rules {
+		invalidorders[Ordering{rule.b, rule.a}] = true
+	}
+	var sb strings.Builder
+	for _, seq := range seqs {
+		if validseq(invalidorders, seq) {
+			continue
+		}
+		sorted, err := sort(rules, seq, policy)
+		if err != nil {
+			return "", err
+		}
+		verdict := "unique"
+		if !graph(rules, seq).Unique() {
+			verdict = "not unique"
+		}
+		fmt.Fprintf(&sb, "%v -> %v: %s\n", seq, sorted, verdict)
+	}
+	return sb.String(), nil
+}
+
+// cycles lists the rule cycle, if any, among the pages of each update.
+func cycles(input string) string {
+	rules, seqs := parse(input)
+	var sb strings.Builder
+	for _, seq := range seqs {
+		var cycle *CycleError
+		if _, err := sort(rules, seq, FAIL); errors.As(err, &cycle) {
+			fmt.Fprintln(&sb, cycle)
+		}
+	}
+	return sb.String()
+}
+
 func main() {
+	oncycle := flag.String("on-cycle", "fail", "what to do when rules for an update form a cycle: fail or tiebreak")
+	listcycles := flag.Bool("cycles", false, "list the rule cycle found in each update")
+	unique := flag.Bool("unique", false, "list each corrected update and whether its order is unique")
+	workers := flag.Int("workers", 0, "solve with the compiled rule index on this many goroutines, streaming the updates")
+	minimal := flag.Bool("minedit", false, "list the fewest page moves that correct each invalid update")
+	flag.Parse()
+	var policy CyclePolicy
+	switch *oncycle {
+	case "fail":
+		policy = FAIL
+	case "tiebreak":
+		policy = TIEBREAK
+	default:
+		log.Fatalf("unknown -on-cycle %q, want fail or tiebreak", *oncycle)
+	}
+
 	f, err := os.Open("../data/day05.txt")
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *workers > 0 {
+		if *listcycles || *unique || *minimal {
+			log.Fatal("-workers cannot be combined with -cycles, -unique or -minedit")
+		}
+		part1, part2, err := solvestream(f, *workers, policy)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Part 1: %d\n", part1)
+		fmt.Printf("Part 2: %d\n", part2)
+		return
+	}
 	b, err := io.ReadAll(f)
 	if err != nil {
 		log.Fatal(err)
 	}
 	s := string(b)
+	if *listcycles {
+		fmt.Print(cycles(s))
+		return
+	}
+	if *minimal {
+		report, err := minedits(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(report)
+		return
+	}
+	if *unique {
+		report, err := uniqueness(s, policy)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(report)
+		return
+	}
 	fmt.Printf("Part 1: %s\n", part1(s))
-	fmt.Printf("Part 2: %s\n", part2(s))
+	n, err := correct(s, policy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Part 2: %d\n", n)
 }
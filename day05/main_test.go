@@ -1,6 +1,14 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -85,3 +93,329 @@ func Test_part2(t *testing.T) {
 		})
 	}
 }
+
+var CYCLE_INPUT string = `1|2
+2|3
+3|1
+4|5
+
+3,2,1
+1,4,5
+5,4,1
+`
+
+func Test_sort(t *testing.T) {
+	rules, _ := parse(CYCLE_INPUT)
+	type args struct {
+		seq    []int
+		policy CyclePolicy
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      []int
+		wantcycle []int
+	}{
+		{
+			name: "no cycle",
+			args: args{seq: []int{5, 4, 1}, policy: FAIL},
+			want: []int{4, 5, 1},
+		},
+		{
+			name:      "cycle fails",
+			args:      args{seq: []int{3, 2, 1}, policy: FAIL},
+			want:      []int{},
+			wantcycle: []int{3, 1, 2},
+		},
+		{
+			name: "cycle tie-break",
+			args: args{seq: []int{3, 2, 1}, policy: TIEBREAK},
+			want: []int{3, 1, 2},
+		},
+		{
+			name: "cycle excluded from the update",
+			args: args{seq: []int{2, 3}, policy: FAIL},
+			want: []int{2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sort(rules, tt.args.seq, tt.args.policy)
+			var cycle *CycleError
+			if errors.As(err, &cycle) {
+				if !reflect.DeepEqual(cycle.Cycle, tt.wantcycle) {
+					t.Errorf("sort() cycle = %v, want %v", cycle.Cycle, tt.wantcycle)
+				}
+			} else if err != nil || tt.wantcycle != nil {
+				t.Errorf("sort() error = %v, want cycle %v", err, tt.wantcycle)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_correct(t *testing.T) {
+	type args struct {
+		input  string
+		policy CyclePolicy
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    int
+		wanterr string
+	}{
+		{name: "test input", args: args{input: TEST_INPUT, policy: FAIL}, want: 123},
+		{name: "cycle fails", args: args{input: CYCLE_INPUT, policy: FAIL}, wanterr: "update [3 2 1]: rules form a cycle 3 -> 1 -> 2 -> 3"},
+		{name: "cycle tie-break", args: args{input: CYCLE_INPUT, policy: TIEBREAK}, want: 1 + 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := correct(tt.args.input, tt.args.policy)
+			if tt.wanterr != "" {
+				if err == nil || err.Error() != tt.wanterr {
+					t.Errorf("correct() error = %v, want %v", err, tt.wanterr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("correct() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("correct() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_cycles(t *testing.T) {
+	want := "update [3 2 1]: rules form a cycle 3 -> 1 -> 2 -> 3\n"
+	if got := cycles(CYCLE_INPUT); got != want {
+		t.Errorf("cycles() = %q, want %q", got, want)
+	}
+}
+
+func Test_uniqueness(t *testing.T) {
+	got, err := uniqueness(TEST_INPUT, FAIL)
+	if err != nil {
+		t.Fatalf("uniqueness() error = %v", err)
+	}
+	want := `[75 97 47 61 53] -> [97 75 47 61 53]: unique
+[61 13 29] -> [61 29 13]: unique
+[97 13 75 29 47] -> [97 75 47 29 13]: unique
+`
+	if got != want {
+		t.Errorf("uniqueness() = %v, want %v", got, want)
+	}
+
+	got, err = uniqueness(CYCLE_INPUT, TIEBREAK)
+	if err != nil {
+		t.Fatalf("uniqueness() error = %v", err)
+	}
+	want = `[3 2 1] -> [3 1 2]: not unique
+[5 4 1] -> [4 5 1]: not unique
+`
+	if got != want {
+		t.Errorf("uniqueness() = %v, want %v", got, want)
+	}
+}
+
+func Test_minedit(t *testing.T) {
+	rules, _ := parse(TEST_INPUT)
+	type args struct {
+		rules []Ordering
+		seq   []int
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      []int
+		wantmoves []Move
+	}{
+		{
+			name:      "one page out of place",
+			args:      args{rules: rules, seq: []int{75, 97, 47, 61, 53}},
+			want:      []int{97, 75, 47, 61, 53},
+			wantmoves: []Move{{Page: 75, From: 0, To: 1}},
+		},
+		{
+			name:      "swap",
+			args:      args{rules: rules, seq: []int{61, 13, 29}},
+			want:      []int{61, 29, 13},
+			wantmoves: []Move{{Page: 13, From: 1, To: 2}},
+		},
+		{
+			name:      "two moves",
+			args:      args{rules: rules, seq: []int{97, 13, 75, 29, 47}},
+			want:      []int{97, 75, 47, 29, 13},
+			wantmoves: []Move{{Page: 29, From: 3, To: 3}, {Page: 13, From: 1, To: 4}},
+		},
+		{
+			name: "partial order uses transitive rules",
+			args: args{
+				rules: []Ordering{{3, 2}, {2, 1}},
+				seq:   []int{1, 3, 2},
+			},
+			want:      []int{3, 2, 1},
+			wantmoves: []Move{{Page: 1, From: 0, To: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, moves, err := minedit(tt.args.rules, tt.args.seq)
+			if err != nil {
+				t.Fatalf("minedit() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("minedit() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(moves, tt.wantmoves) {
+				t.Errorf("minedit() moves = %v, want %v", moves, tt.wantmoves)
+			}
+		})
+	}
+}
+
+func Test_maxantichain(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	for range 200 {
+		n := 1 + r.Intn(8)
+		// A random strict partial order: the transitive closure of random
+		// forward edges.
+		less := make([][]bool, n)
+		for i := range less {
+			less[i] = make([]bool, n)
+			for j := i + 1; j < n; j++ {
+				less[i][j] = r.Intn(3) == 0
+			}
+		}
+		for k := range n {
+			for i := range n {
+				for j := range n {
+					less[i][j] = less[i][j] || (less[i][k] && less[k][j])
+				}
+			}
+		}
+		got := maxantichain(n, func(i, j int) bool { return less[i][j] })
+		size := 0
+		for i := range n {
+			if !got[i] {
+				continue
+			}
+			size++
+			for j := range n {
+				if got[j] && less[i][j] {
+					t.Fatalf("maxantichain() holds related %d < %d", i, j)
+				}
+			}
+		}
+		best := 0
+		for set := 0; set < 1<<n; set++ {
+			ok := true
+			for i := range n {
+				for j := range n {
+					if set&(1<<i) != 0 && set&(1<<j) != 0 && less[i][j] {
+						ok = false
+					}
+				}
+			}
+			if ok {
+				best = max(best, bits.OnesCount(uint(set)))
+			}
+		}
+		if size != best {
+			t.Errorf("maxantichain() size = %d, want %d", size, best)
+		}
+	}
+}
+
+// randomqueue builds rules putting npages pages in a random total order,
+// followed by nupdates random updates of odd length.
+func randomqueue(npages int, nupdates int, seed int64) string {
+	r := rand.New(rand.NewSource(seed))
+	pages := r.Perm(npages)
+	var sb strings.Builder
+	for i := range pages {
+		for j := i + 1; j < len(pages); j++ {
+			fmt.Fprintf(&sb, "%d|%d\n", pages[i]+10, pages[j]+10)
+		}
+	}
+	sb.WriteString("\n")
+	for range nupdates {
+		n := 2*r.Intn(min(npages/2, 10)) + 1
+		var nums []string
+		for _, p := range r.Perm(npages)[:n] {
+			nums = append(nums, strconv.Itoa(p+10))
+		}
+		sb.WriteString(strings.Join(nums, ",") + "\n")
+	}
+	return sb.String()
+}
+
+func Test_solvestream(t *testing.T) {
+	inputs := []string{TEST_INPUT, randomqueue(30, 500, 1), randomqueue(200, 300, 2)}
+	for i, input := range inputs {
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("input %d workers %d", i, workers), func(t *testing.T) {
+				part1got, part2got, err := solvestream(strings.NewReader(input), workers, FAIL)
+				if err != nil {
+					t.Fatalf("solvestream() error = %v", err)
+				}
+				if want := part1(input); fmt.Sprint(part1got) != want {
+					t.Errorf("solvestream() part1 = %v, want %v", part1got, want)
+				}
+				if want := part2(input); fmt.Sprint(part2got) != want {
+					t.Errorf("solvestream() part2 = %v, want %v", part2got, want)
+				}
+			})
+		}
+	}
+
+	_, _, err := solvestream(strings.NewReader(CYCLE_INPUT), 2, FAIL)
+	var cycle *CycleError
+	if !errors.As(err, &cycle) || err.Error() != "update [3 2 1]: rules form a cycle 3 -> 1 -> 2 -> 3" {
+		t.Errorf("solvestream() on cyclic rules: error = %v, want the cycle 3 -> 1 -> 2 -> 3", err)
+	}
+
+	part1got, part2got, err := solvestream(strings.NewReader(CYCLE_INPUT), 2, TIEBREAK)
+	if err != nil {
+		t.Fatalf("solvestream() tie-break error = %v", err)
+	}
+	part2want, _ := correct(CYCLE_INPUT, TIEBREAK)
+	if want := part1(CYCLE_INPUT); fmt.Sprint(part1got) != want || part2got != part2want {
+		t.Errorf("solvestream() tie-break = %v, %v, want %v, %v", part1got, part2got, want, part2want)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("1|2\n2|3\n3|1\n\n")
+	for i := range 500 {
+		fmt.Fprintf(&sb, "%d,%d,%d\n", i+10, i+11, i+12)
+	}
+	for i := range 100 {
+		fmt.Fprintf(&sb, "%d,3,2,1,%d\n", i+100, i+100)
+	}
+	want := "update [100 3 2 1 100]: rules form a cycle 3 -> 1 -> 2 -> 3"
+	for range 20 {
+		_, _, err := solvestream(strings.NewReader(sb.String()), 8, FAIL)
+		if err == nil || err.Error() != want {
+			t.Fatalf("solvestream() error = %v, want %v", err, want)
+		}
+	}
+}
+
+func BenchmarkSolve(b *testing.B) {
+	input := randomqueue(90, 100000, 3)
+	b.Run("part1+part2", func(b *testing.B) {
+		for range b.N {
+			part1(input)
+			part2(input)
+		}
+	})
+	b.Run("solvestream", func(b *testing.B) {
+		for range b.N {
+			solvestream(strings.NewReader(input), runtime.NumCPU(), FAIL)
+		}
+	})
+}
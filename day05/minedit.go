@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Move takes Page from index From of an update to index To of its corrected
+// order. From and To index different sequences, so they may be equal: the
+// pages around Page can shift past it, as in 97,13,75,29,47 -> 97,75,47,29,13,
+// where 29 is moved from index 3 to index 3 to get it after 47.
+type Move struct {
+	Page int
+	From int
+	To   int
+}
+
+// maxantichain returns a largest set of elements 0..n-1 no two of which are
+// related by the strict partial order less. By Dilworth's and König's
+// theorems it is found from a maximum matching in the bipartite graph with
+// an edge u->v for each u < v: the elements whose left copy is reachable
+// from an unmatched left vertex by alternating paths, but whose right copy
+// is not.
+func maxantichain(n int, less func(int, int) bool) []bool {
+	matchl := make([]int, n)
+	matchr := make([]int, n)
+	for i := range n {
+		matchl[i], matchr[i] = -1, -1
+	}
+	var visited []bool
+	var augment func(u int) bool
+	augment = func(u int) bool {
+		for v := range n {
+			if !less(u, v) || visited[v] {
+				continue
+			}
+			visited[v] = true
+			if matchr[v] < 0 || augment(matchr[v]) {
+				matchl[u], matchr[v] = v, u
+				return true
+			}
+		}
+		return false
+	}
+	for u := range n {
+		visited = make([]bool, n)
+		augment(u)
+	}
+
+	zl := make([]bool, n)
+	zr := make([]bool, n)
+	var reach func(u int)
+	reach = func(u int) {
+		if zl[u] {
+			return
+		}
+		zl[u] = true
+		for v := range n {
+			if less(u, v) && matchl[u] != v && !zr[v] {
+				zr[v] = true
+				if matchr[v] >= 0 {
+					reach(matchr[v])
+				}
+			}
+		}
+	}
+	for u := range n {
+		if matchl[u] < 0 {
+			reach(u)
+		}
+	}
+	antichain := make([]bool, n)
+	for x := range n {
+		antichain[x] = zl[x] && !zr[x]
+	}
+	return antichain
+}
+
+// minedit corrects seq while moving as few pages as possible. The pages left
+// in place must contain no pair that the rules, followed transitively, put
+// the other way round. Positions i < j with seq[j] ordered before seq[i] form
+// a partial order, so the largest such set of pages is a maximum antichain of
+// it. The remaining pages are then moved into a topological order that keeps
+// the others in their original sequence.
+func minedit(rules []Ordering, seq []int) ([]int, []Move, error) {
+	if _, err := sort(rules, seq, FAIL); err != nil {
+		return nil, nil, err
+	}
+	n := len(seq)
+	index := make(map[int]int, n)
+	for i, x := range seq {
+		index[x] = i
+	}
+	// before[i][j] is true if seq[i] must come before seq[j].
+	before := make([][]bool, n)
+	for i := range before {
+		before[i] = make([]bool, n)
+	}
+	for _, rule := range rules {
+		i, iok := index[rule.a]
+		j, jok := index[rule.b]
+		if iok && jok {
+			before[i][j] = true
+		}
+	}
+	for k := range n {
+		for i := range n {
+			if !before[i][k] {
+				continue
+			}
+			for j := range n {
+				if before[k][j] {
+					before[i][j] = true
+				}
+			}
+		}
+	}
+	keep := maxantichain(n, func(i, j int) bool {
+		return i < j && before[j][i]
+	})
+
+	g := graph(rules, seq)
+	prev := -1
+	for i, x := range seq {
+		if !keep[i] {
+			continue
+		}
+		if prev >= 0 {
+			g.AddEdge(seq[prev], x)
+		}
+		prev = i
+	}
+	corrected, err := g.Sort()
+	if err != nil {
+		return nil, nil, err
+	}
+	var moves []Move
+	for to, x := range corrected {
+		if from := index[x]; !keep[from] {
+			moves = append(moves, Move{Page: x, From: from, To: to})
+		}
+	}
+	return corrected, moves, nil
+}
+
+// minedits lists, for each invalid update, the fewest page moves that make
+// it valid and the resulting order.
+func minedits(input string) (string, error) {
+	rules, seqs := parse(input)
+	invalidorders := make(map[Ordering]bool)
+	for _, rule := range rules {
+		invalidorders[Ordering{rule.b, rule.a}] = true
+	}
+	var sb strings.Builder
+	for _, seq := range seqs {
+		if validseq(invalidorders, seq) {
+			continue
+		}
+		corrected, moves, err := minedit(rules, seq)
+		if err != nil {
+			return "", err
+		}
+		var desc []string
+		for _, m := range moves {
+			desc = append(desc, fmt.Sprintf("move %d from %d to %d", m.Page, m.From, m.To))
+		}
+		fmt.Fprintf(&sb, "%v -> %v: %s\n", seq, corrected, strings.Join(desc, ", "))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import "log"
+
+// jumptable holds, for every cell and direction, the cell the guard stops at
+// when walking that way from it: the last free cell before an obstacle, or
+// -1 if she walks off the map. Cells are indexed y*w+x.
+type jumptable struct {
+	w    int
+	h    int
+	next [4][]int
+}
+
+func newjumptable(grid []string) *jumptable {
+	h, w := len(grid), len(grid[0])
+	jt := &jumptable{w: w, h: h}
+	for d := range jt.next {
+		jt.next[d] = make([]int, w*h)
+	}
+	for x := 0; x < w; x++ {
+		stop := -1
+		for y := 0; y < h; y++ {
+			if grid[y][x] == '#' {
+				stop = -1
+				if y+1 < h {
+					stop = (y+1)*w + x
+				}
+			}
+			jt.next[N][y*w+x] = stop
+		}
+		stop = -1
+		for y := h - 1; y >= 0; y-- {
+			if grid[y][x] == '#' {
+				stop = -1
+				if y > 0 {
+					stop = (y-1)*w + x
+				}
+			}
+			jt.next[S][y*w+x] = stop
+		}
+	}
+	for y := 0; y < h; y++ {
+		stop := -1
+		for x := 0; x < w; x++ {
+			if grid[y][x] == '#' {
+				stop = -1
+				if x+1 < w {
+					stop = y*w + x + 1
+				}
+			}
+			jt.next[W][y*w+x] = stop
+		}
+		stop = -1
+		for x := w - 1; x >= 0; x-- {
+			if grid[y][x] == '#' {
+				stop = -1
+				if x > 0 {
+					stop = y*w + x - 1
+				}
+			}
+			jt.next[E][y*w+x] = stop
+		}
+	}
+	return jt
+}
+
+// jump returns where the guard at cell from stops walking in direction dir
+// if an extra obstacle is placed at cell block, or -1 if she leaves the map.
+func (jt *jumptable) jump(from int, dir Dir, block int) int {
+	stop := jt.next[dir][from]
+	x, y := from%jt.w, from/jt.w
+	bx, by := block%jt.w, block/jt.w
+	switch dir {
+	case N:
+		if bx == x && by < y && (stop < 0 || by >= stop/jt.w) {
+			return (by+1)*jt.w + x
+		}
+	case S:
+		if bx == x && by > y && (stop < 0 || by <= stop/jt.w) {
+			return (by-1)*jt.w + x
+		}
+	case W:
+		if by == y && bx < x && (stop < 0 || bx >= stop%jt.w) {
+			return y*jt.w + bx + 1
+		}
+	case E:
+		if by == y && bx > x && (stop < 0 || bx <= stop%jt.w) {
+			return y*jt.w + bx - 1
+		}
+	}
+	return stop
+}
+
+// loops reports whether the guard starting at start gets stuck in a loop
+// once an extra obstacle is placed at cell block. She is moved from one
+// turning point to the next, and a loop is a repeated turning point and
+// direction. seen is a scratch buffer of 4*w*h entries; entries equal to
+// gen mark states seen in this run, so callers can reuse it by passing a
+// new gen each time.
+func (jt *jumptable) loops(start Pos, block int, seen []int, gen int) bool {
+	cell, dir := start.y*jt.w+start.x, start.dir
+	for {
+		state := cell*4 + int(dir)
+		if seen[state] == gen {
+			return true
+		}
+		seen[state] = gen
+		cell = jt.jump(cell, dir, block)
+		if cell < 0 {
+			return false
+		}
+		dir = (dir + 1) % 4
+	}
+}
+
+// route returns the cells the guard visits on her original patrol, in the
+// order she first reaches them.
+func route(grid []string, pos Pos) []Coord {
+	visited := make(map[Coord]bool)
+	var cells []Coord
+	done := false
+	var err error
+	for !done {
+		c := Coord{pos.x, pos.y}
+		if !visited[c] {
+			visited[c] = true
+			cells = append(cells, c)
+		}
+		pos, done, err = step(grid, pos)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	return cells
+}
+
+// Loop describes the cycle the guard falls into once Obstacle is placed. She
+// first repeats herself at Entry heading EntryDir; one lap is Length steps
+// and runs through the directions in Dirs.
+type Loop struct {
+	Obstacle Coord
+	Entry    Coord
+	EntryDir Dir
+	Length   int
+	Dirs     []Dir
+}
+
+// loop is like loops but returns the cycle the guard ends up in.
+func (jt *jumptable) loop(start Pos, block int) (Loop, bool) {
+	type state struct {
+		cell int
+		dir  Dir
+	}
+	index := map[state]int{}
+	var path []state
+	s := state{start.y*jt.w + start.x, start.dir}
+	for {
+		if i, ok := index[s]; ok {
+			l := Loop{
+				Obstacle: Coord{block % jt.w, block / jt.w},
+				Entry:    Coord{s.cell % jt.w, s.cell / jt.w},
+				EntryDir: s.dir,
+			}
+			cycle := append(path[i:], s)
+			for j := 0; j+1 < len(cycle); j++ {
+				a, b := cycle[j].cell, cycle[j+1].cell
+				l.Length += abs(a%jt.w-b%jt.w) + abs(a/jt.w-b/jt.w)
+				l.Dirs = append(l.Dirs, cycle[j].dir)
+			}
+			return l, true
+		}
+		index[s] = len(path)
+		path = append(path, s)
+		next := jt.jump(s.cell, s.dir, block)
+		if next < 0 {
+			return Loop{}, false
+		}
+		s = state{next, (s.dir + 1) % 4}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"runtime"
 	"strings"
+	"sync"
 )
 
 type Dir int
@@ -17,6 +20,10 @@ const (
 	W
 )
 
+func (d Dir) String() string {
+	return [...]string{"N", "E", "S", "W"}[d]
+}
+
 type Coord struct {
 	x int
 	y int
@@ -91,52 +98,127 @@ func step(grid []string, pos Pos) (Pos, bool, error) {
 
 func part1(input string) string {
 	grid, pos := parse(input)
-	visited := make(map[Coord]bool)
+	return fmt.Sprint(len(route(grid, pos)))
+}
+
+// loopobstacles returns the cells where a new obstacle traps the guard in a
+// loop, in the order her original route reaches them. Only cells on that
+// route are tried, since an obstacle anywhere else is never reached. The
+// candidates are split across jobs goroutines, each with its own visited
+// buffer, and the result does not depend on jobs.
+func loopobstacles(grid []string, pos Pos, jobs int) []Coord {
+	jt := newjumptable(grid)
+	var candidates []Coord
+	for _, c := range route(grid, pos) {
+		if c.x != pos.x || c.y != pos.y {
+			candidates = append(candidates, c)
+		}
+	}
+	loops := make([]bool, len(candidates))
+	jobs = max(1, min(jobs, len(candidates)))
+	var wg sync.WaitGroup
+	for k := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen := make([]int, 4*jt.w*jt.h)
+			for i := k; i < len(candidates); i += jobs {
+				c := candidates[i]
+				loops[i] = jt.loops(pos, c.y*jt.w+c.x, seen, i+1)
+			}
+		}()
+	}
+	wg.Wait()
+	var obstacles []Coord
+	for i, c := range candidates {
+		if loops[i] {
+			obstacles = append(obstacles, c)
+		}
+	}
+	return obstacles
+}
+
+// render draws the guard's original patrol over the map: '|' where she moves
+// north or south, '-' where she moves east or west, and '+' where she does
+// both or turns. Her starting cell keeps its original glyph.
+func render(grid []string, pos Pos) string {
+	const (
+		VERTICAL = 1 << iota
+		HORIZONTAL
+	)
+	marks := make([][]int, len(grid))
+	for y := range marks {
+		marks[y] = make([]int, len(grid[y]))
+	}
+	start := pos
 	done := false
 	var err error
 	for !done {
-		visited[Coord{pos.x, pos.y}] = true
+		if pos.dir == N || pos.dir == S {
+			marks[pos.y][pos.x] |= VERTICAL
+		} else {
+			marks[pos.y][pos.x] |= HORIZONTAL
+		}
 		pos, done, err = step(grid, pos)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	return fmt.Sprint(len(visited))
+	var sb strings.Builder
+	for y, line := range grid {
+		for x := 0; x < len(line); x++ {
+			switch {
+			case x == start.x && y == start.y:
+				sb.WriteByte("^>v<"[start.dir])
+			case marks[y][x] == VERTICAL:
+				sb.WriteByte('|')
+			case marks[y][x] == HORIZONTAL:
+				sb.WriteByte('-')
+			case marks[y][x] != 0:
+				sb.WriteByte('+')
+			default:
+				sb.WriteByte(line[x])
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
 }
 
-func part2(input string) string {
+// report renders the original patrol, then lists each obstacle position
+// that traps the guard with the loop it causes.
+func report(input string, jobs int) string {
 	grid, pos := parse(input)
-	n := 0
-	for y := 0; y < len(grid); y++ {
-		for x := 0; x < len(grid[y]); x++ {
-			if x == pos.x && y == pos.y || grid[y][x] == '#' {
-				continue
-			}
-			blocked := make([]string, len(grid))
-			copy(blocked, grid)
-			blocked[y] = blocked[y][0:x] + "#" + blocked[y][x+1:]
-			visitedpositions := make(map[Pos]bool)
-			done := false
-			var err error
-			p := pos
-			for !done {
-				if visitedpositions[p] {
-					n++
-					break
-				}
-				visitedpositions[p] = true
-				p, done, err = step(blocked, p)
-				if err != nil {
-					log.Fatal(err)
-				}
-			}
+	jt := newjumptable(grid)
+	var sb strings.Builder
+	sb.WriteString(render(grid, pos))
+	for _, c := range loopobstacles(grid, pos, jobs) {
+		l, _ := jt.loop(pos, c.y*jt.w+c.x)
+		dirs := make([]string, len(l.Dirs))
+		for i, d := range l.Dirs {
+			dirs[i] = d.String()
 		}
+		fmt.Fprintf(&sb, "(%d,%d): loop entered at (%d,%d) heading %s, %d steps, %s\n",
+			c.x, c.y, l.Entry.x, l.Entry.y, l.EntryDir, l.Length, strings.Join(dirs, " "))
 	}
-	return fmt.Sprint(n)
+	return sb.String()
+}
+
+func solve2(input string, jobs int) string {
+	grid, pos := parse(input)
+	return fmt.Sprint(len(loopobstacles(grid, pos, jobs)))
+}
+
+func part2(input string) string {
+	return solve2(input, runtime.NumCPU())
 }
 
 func main() {
+	jobs := flag.Int("jobs", runtime.NumCPU(), "number of goroutines evaluating obstacle positions")
+	showreport := flag.Bool("report", false, "draw the patrol route and list each loop-causing obstacle with its loop")
+	flag.Parse()
+
 	f, err := os.Open("../data/day06.txt")
 	if err != nil {
 		log.Fatal(err)
@@ -146,6 +228,10 @@ func main() {
 		log.Fatal(err)
 	}
 	s := string(b)
+	if *showreport {
+		fmt.Print(report(s, *jobs))
+		return
+	}
 	fmt.Printf("Part 1: %s\n", part1(s))
-	fmt.Printf("Part 2: %s\n", part2(s))
+	fmt.Printf("Part 2: %s\n", solve2(s, *jobs))
 }
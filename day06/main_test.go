@@ -1,6 +1,12 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -65,3 +71,160 @@ func Test_part2(t *testing.T) {
 		})
 	}
 }
+
+// bruteforce is the original part2: it tries an obstacle on every free cell
+// and walks the guard one step at a time.
+func bruteforce(input string) string {
+	grid, pos := parse(input)
+	n := 0
+	for y := 0; y < len(grid); y++ {
+		for x := 0; x < len(grid[y]); x++ {
+			if x == pos.x && y == pos.y || grid[y][x] == '#' {
+				continue
+			}
+			blocked := make([]string, len(grid))
+			copy(blocked, grid)
+			blocked[y] = blocked[y][0:x] + "#" + blocked[y][x+1:]
+			visitedpositions := make(map[Pos]bool)
+			done := false
+			var err error
+			p := pos
+			for !done {
+				if visitedpositions[p] {
+					n++
+					break
+				}
+				visitedpositions[p] = true
+				p, done, err = step(blocked, p)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+	}
+	return fmt.Sprint(n)
+}
+
+func randomlab(w int, h int, density float64, seed int64) string {
+	r := rand.New(rand.NewSource(seed))
+	rows := make([][]byte, h)
+	for y := range rows {
+		rows[y] = make([]byte, w)
+		for x := range rows[y] {
+			rows[y][x] = '.'
+			if r.Float64() < density {
+				rows[y][x] = '#'
+			}
+		}
+	}
+	rows[r.Intn(h)][r.Intn(w)] = "^>v<"[r.Intn(4)]
+	lines := make([]string, h)
+	for y, row := range rows {
+		lines[y] = string(row)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func Test_part2_bruteforce(t *testing.T) {
+	for seed := range int64(30) {
+		input := randomlab(12+int(seed), 9+int(seed)/2, 0.12, seed)
+		t.Run(fmt.Sprint("seed ", seed), func(t *testing.T) {
+			if got, want := part2(input), bruteforce(input); got != want {
+				t.Errorf("part2() = %v, want %v for\n%s", got, want, input)
+			}
+		})
+	}
+}
+
+func Test_loopobstacles(t *testing.T) {
+	grid, pos := parse(TEST_INPUT)
+	// The six positions from the puzzle, in the order the route reaches them.
+	want := []Coord{{3, 6}, {6, 7}, {3, 8}, {1, 8}, {7, 7}, {7, 9}}
+	for _, jobs := range []int{1, 2, 3, 8, 1000} {
+		t.Run(fmt.Sprint(jobs, " jobs"), func(t *testing.T) {
+			if got := loopobstacles(grid, pos, jobs); !reflect.DeepEqual(got, want) {
+				t.Errorf("loopobstacles() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_render(t *testing.T) {
+	grid, pos := parse(TEST_INPUT)
+	want := `....#.....
+....+---+#
+....|...|.
+..#.|...|.
+..+-+-+#|.
+..|.|.|.|.
+.#+-^-+-+.
+.+----++#.
+#+----+|..
+......#|..
+`
+	if got := render(grid, pos); got != want {
+		t.Errorf("render() = \n%v, want \n%v", got, want)
+	}
+}
+
+func Test_loop(t *testing.T) {
+	grid, pos := parse(TEST_INPUT)
+	jt := newjumptable(grid)
+	type args struct {
+		block Coord
+	}
+	tests := []struct {
+		name   string
+		args   args
+		want   Loop
+		wantok bool
+	}{
+		{
+			name: "beside the start",
+			args: args{block: Coord{3, 6}},
+			want: Loop{
+				Obstacle: Coord{3, 6},
+				Entry:    Coord{4, 6},
+				EntryDir: N,
+				Length:   18,
+				Dirs:     []Dir{N, E, S, W},
+			},
+			wantok: true,
+		},
+		{
+			name:   "no loop",
+			args:   args{block: Coord{0, 0}},
+			wantok: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := jt.loop(pos, tt.args.block.y*jt.w+tt.args.block.x)
+			if ok != tt.wantok {
+				t.Fatalf("loop() ok = %v, want %v", ok, tt.wantok)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loop() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkPart2(b *testing.B) {
+	input := randomlab(130, 130, 0.02, 7)
+	b.Run("jumptable", func(b *testing.B) {
+		for range b.N {
+			solve2(input, 1)
+		}
+	})
+	b.Run("jumptable parallel", func(b *testing.B) {
+		for range b.N {
+			solve2(input, runtime.NumCPU())
+		}
+	})
+	b.Run("bruteforce", func(b *testing.B) {
+		for range b.N {
+			bruteforce(input)
+		}
+	})
+}
@@ -0,0 +1,238 @@
+// Package toposort orders the nodes of a directed graph so that every edge
+// points forward, using Kahn's algorithm. Nodes that are ready at the same
+// time are always taken in the order they were added, so results are
+// deterministic.
+package toposort
+
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Graph is a directed graph over comparable nodes. An edge from a to b
+// means a must come before b.
+type Graph[T comparable] struct {
+	nodes []T
+	index map[T]int
+	succ  [][]int
+	pred  [][]int
+	edges map[[2]int]bool
+}
+
+func New[T comparable]() *Graph[T] {
+	return &Graph[T]{index: map[T]int{}, edges: map[[2]int]bool{}}
+}
+
+// AddNode adds n to the graph if it is not already there. The order in which
+// nodes are added breaks ties between nodes that are ready together.
+func (g *Graph[T]) AddNode(n T) {
+	g.id(n)
+}
+
+func (g *Graph[T]) id(n T) int {
+	if i, ok := g.index[n]; ok {
+		return i
+	}
+	i := len(g.nodes)
+	g.index[n] = i
+	g.nodes = append(g.nodes, n)
+	g.succ = append(g.succ, nil)
+	g.pred = append(g.pred, nil)
+	return i
+}
+
+// AddEdge records that from must come before to, adding either node if
+// needed. Repeated edges are ignored.
+func (g *Graph[T]) AddEdge(from T, to T) {
+	a, b := g.id(from), g.id(to)
+	if g.edges[[2]int{a, b}] {
+		return
+	}
+	g.edges[[2]int{a, b}] = true
+	g.succ[a] = append(g.succ[a], b)
+	g.pred[b] = append(g.pred[b], a)
+}
+
+func (g *Graph[T]) Len() int {
+	return len(g.nodes)
+}
+
+// CycleError is returned when no ordering exists. Each node in Cycle must
+// come before the next, and the last before the first.
+type CycleError[T comparable] struct {
+	Cycle []T
+}
+
+func (e *CycleError[T]) Error() string {
+	parts := make([]string, len(e.Cycle)+1)
+	for i, n := range e.Cycle {
+		parts[i] = fmt.Sprint(n)
+	}
+	parts[len(e.Cycle)] = parts[0]
+	return "cycle " + strings.Join(parts, " -> ")
+}
+
+// ready is a min-heap of node ids, so the earliest-added ready node is
+// always taken first.
+type ready []int
+
+func (r ready) Len() int           { return len(r) }
+func (r ready) Less(i, j int) bool { return r[i] < r[j] }
+func (r ready) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r *ready) Push(x any)        { *r = append(*r, x.(int)) }
+func (r *ready) Pop() any {
+	old := *r
+	x := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return x
+}
+
+func (g *Graph[T]) indegrees() []int {
+	indeg := make([]int, len(g.nodes))
+	for i := range g.nodes {
+		indeg[i] = len(g.pred[i])
+	}
+	return indeg
+}
+
+// kahn runs Kahn's algorithm. If it stalls on a cycle it either stops,
+// returning the ids placed so far and the id it stalled at, or, with force
+// set, places the earliest-added remaining node regardless of its inbound
+// edges. unique reports whether exactly one node was ready at every step.
+func (g *Graph[T]) kahn(force bool) (order []int, stalled int, unique bool) {
+	indeg := g.indegrees()
+	placed := make([]bool, len(g.nodes))
+	var r ready
+	for i, d := range indeg {
+		if d == 0 {
+			r = append(r, i)
+		}
+	}
+	unique = true
+	for len(order) < len(g.nodes) {
+		if r.Len() == 0 {
+			stalled = slices.Index(placed, false)
+			if !force {
+				return order, stalled, false
+			}
+			unique = false
+			indeg[stalled] = 0
+			heap.Push(&r, stalled)
+		}
+		if r.Len() > 1 {
+			unique = false
+		}
+		i := heap.Pop(&r).(int)
+		order = append(order, i)
+		placed[i] = true
+		for _, j := range g.succ[i] {
+			indeg[j]--
+			if indeg[j] == 0 && !placed[j] {
+				heap.Push(&r, j)
+			}
+		}
+	}
+	return order, -1, unique
+}
+
+func (g *Graph[T]) ids(order []int) []T {
+	nodes := make([]T, len(order))
+	for i, id := range order {
+		nodes[i] = g.nodes[id]
+	}
+	return nodes
+}
+
+// cycle follows unplaced predecessors back from start until a node repeats,
+// and returns the loop starting from its earliest-added node.
+func (g *Graph[T]) cycle(order []int, start int) []T {
+	placed := make([]bool, len(g.nodes))
+	for _, i := range order {
+		placed[i] = true
+	}
+	seen := map[int]int{}
+	var path []int
+	for node := start; ; {
+		if i, ok := seen[node]; ok {
+			cycle := path[i:]
+			slices.Reverse(cycle)
+			first := slices.Index(cycle, slices.Min(cycle))
+			return g.ids(slices.Concat(cycle[first:], cycle[:first]))
+		}
+		seen[node] = len(path)
+		path = append(path, node)
+		for _, p := range g.pred[node] {
+			if !placed[p] {
+				node = p
+				break
+			}
+		}
+	}
+}
+
+// Sort returns the nodes in an order where every edge points forward, or a
+// *CycleError if there is none. Of all such orders it returns the one that
+// takes ready nodes in the order they were added.
+func (g *Graph[T]) Sort() ([]T, error) {
+	order, stalled, _ := g.kahn(false)
+	if stalled >= 0 {
+		return g.ids(order), &CycleError[T]{Cycle: g.cycle(order, stalled)}
+	}
+	return g.ids(order), nil
+}
+
+// SortForcing is like Sort, but whenever every remaining node lies on or
+// behind a cycle it places the earliest-added of them and carries on, so it
+// always returns every node.
+func (g *Graph[T]) SortForcing() []T {
+	order, _, _ := g.kahn(true)
+	return g.ids(order)
+}
+
+// Unique reports whether the graph has exactly one valid ordering, which is
+// the case when Kahn's algorithm never has a choice of ready nodes.
+func (g *Graph[T]) Unique() bool {
+	_, stalled, unique := g.kahn(false)
+	return stalled < 0 && unique
+}
+
+// All returns every valid ordering, stopping after limit of them if limit is
+// positive. The count grows factorially with the number of unordered nodes,
+// so this is meant for small graphs.
+func (g *Graph[T]) All(limit int) [][]T {
+	indeg := g.indegrees()
+	placed := make([]bool, len(g.nodes))
+	order := make([]int, 0, len(g.nodes))
+	var all [][]T
+	var visit func() bool
+	visit = func() bool {
+		if len(order) == len(g.nodes) {
+			all = append(all, g.ids(order))
+			return limit <= 0 || len(all) < limit
+		}
+		for i := range g.nodes {
+			if placed[i] || indeg[i] != 0 {
+				continue
+			}
+			placed[i] = true
+			order = append(order, i)
+			for _, j := range g.succ[i] {
+				indeg[j]--
+			}
+			more := visit()
+			for _, j := range g.succ[i] {
+				indeg[j]++
+			}
+			order = order[:len(order)-1]
+			placed[i] = false
+			if !more {
+				return false
+			}
+		}
+		return true
+	}
+	visit()
+	return all
+}
@@ -0,0 +1,159 @@
+package toposort
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func build(nodes []string, edges [][2]string) *Graph[string] {
+	g := New[string]()
+	for _, n := range nodes {
+		g.AddNode(n)
+	}
+	for _, e := range edges {
+		g.AddEdge(e[0], e[1])
+	}
+	return g
+}
+
+func TestGraph_Sort(t *testing.T) {
+	type args struct {
+		nodes []string
+		edges [][2]string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      []string
+		wantcycle []string
+	}{
+		{
+			name: "chain",
+			args: args{
+				nodes: []string{"c", "b", "a"},
+				edges: [][2]string{{"a", "b"}, {"b", "c"}},
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "ties keep insertion order",
+			args: args{
+				nodes: []string{"d", "c", "b", "a"},
+				edges: [][2]string{{"a", "b"}, {"c", "b"}},
+			},
+			want: []string{"d", "c", "a", "b"},
+		},
+		{
+			name: "repeated edges",
+			args: args{
+				edges: [][2]string{{"a", "b"}, {"a", "b"}},
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "cycle",
+			args: args{
+				nodes: []string{"x", "a", "b", "c"},
+				edges: [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"x", "a"}},
+			},
+			want:      []string{"x"},
+			wantcycle: []string{"a", "b", "c"},
+		},
+		{
+			name: "self loop",
+			args: args{
+				edges: [][2]string{{"a", "a"}},
+			},
+			want:      []string{},
+			wantcycle: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := build(tt.args.nodes, tt.args.edges).Sort()
+			var cycle *CycleError[string]
+			if errors.As(err, &cycle) {
+				if !reflect.DeepEqual(cycle.Cycle, tt.wantcycle) {
+					t.Errorf("Sort() cycle = %v, want %v", cycle.Cycle, tt.wantcycle)
+				}
+			} else if err != nil || tt.wantcycle != nil {
+				t.Errorf("Sort() error = %v, want cycle %v", err, tt.wantcycle)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraph_SortForcing(t *testing.T) {
+	g := build([]string{"c", "b", "a", "z"}, [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"a", "z"}})
+	want := []string{"c", "a", "b", "z"}
+	if got := g.SortForcing(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SortForcing() = %v, want %v", got, want)
+	}
+}
+
+func TestGraph_Unique(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph[string]
+		want  bool
+	}{
+		{name: "chain", graph: build(nil, [][2]string{{"a", "b"}, {"b", "c"}}), want: true},
+		{name: "fork", graph: build(nil, [][2]string{{"a", "b"}, {"a", "c"}}), want: false},
+		{name: "cycle", graph: build(nil, [][2]string{{"a", "b"}, {"b", "a"}}), want: false},
+		{name: "empty", graph: New[string](), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.graph.Unique(); got != tt.want {
+				t.Errorf("Unique() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraph_All(t *testing.T) {
+	type args struct {
+		graph *Graph[int]
+		limit int
+	}
+	diamond := New[int]()
+	diamond.AddEdge(1, 2)
+	diamond.AddEdge(1, 3)
+	diamond.AddEdge(2, 4)
+	diamond.AddEdge(3, 4)
+	cyclic := New[int]()
+	cyclic.AddEdge(1, 2)
+	cyclic.AddEdge(2, 1)
+	tests := []struct {
+		name string
+		args args
+		want [][]int
+	}{
+		{
+			name: "diamond",
+			args: args{graph: diamond},
+			want: [][]int{{1, 2, 3, 4}, {1, 3, 2, 4}},
+		},
+		{
+			name: "limited",
+			args: args{graph: diamond, limit: 1},
+			want: [][]int{{1, 2, 3, 4}},
+		},
+		{
+			name: "cycle",
+			args: args{graph: cyclic},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.args.graph.All(tt.args.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("All() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}